@@ -0,0 +1,97 @@
+package helper
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Page size bounds shared by every paginated RPC.
+const (
+	DefaultPageSize int32 = 20
+	MaxPageSize     int32 = 100
+)
+
+// PageSize clamps the page size requested by a client to [1, MaxPageSize],
+// falling back to DefaultPageSize when none was given.
+func PageSize(requested int32) int32 {
+	if requested <= 0 {
+		return DefaultPageSize
+	}
+	if requested > MaxPageSize {
+		return MaxPageSize
+	}
+	return requested
+}
+
+// EncodeOffsetToken turns a row offset into an opaque page token.
+func EncodeOffsetToken(offset int32) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(int64(offset), 10)))
+}
+
+// DecodeOffsetToken parses a token produced by EncodeOffsetToken.
+// An empty token means the first page.
+func DecodeOffsetToken(token string) (int32, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := strconv.ParseInt(string(raw), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset in page token")
+	}
+
+	return int32(offset), nil
+}
+
+// Keyset is the position of the last row of a page ordered by (created_at, id).
+type Keyset struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// EncodeKeysetToken turns the last row of a page into an opaque page token.
+func EncodeKeysetToken(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeKeysetToken parses a token produced by EncodeKeysetToken.
+// An empty token means the first page and yields a nil Keyset.
+func DecodeKeysetToken(token string) (*Keyset, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, errors.New("malformed page token")
+	}
+
+	keyset := &Keyset{}
+	if keyset.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, err
+	}
+	if keyset.ID, err = uuid.Parse(id); err != nil {
+		return nil, err
+	}
+
+	return keyset, nil
+}
@@ -0,0 +1,17 @@
+package helper
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the Postgres error code for a broken UNIQUE constraint.
+const uniqueViolation = "23505"
+
+// IsUniqueViolation reports whether err was caused by a UNIQUE constraint, such as
+// two accounts claiming the same email.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
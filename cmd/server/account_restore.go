@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	authService "github.com/imhasandl/auth-service/cmd/auth"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/purge"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// Rate limits of RestoreAccount, per rateLimitWindow. It checks passwords, so it
+// is limited like a login.
+const (
+	maxRestoresPerIdentifier = 10
+	maxRestoresPerIP         = 50
+)
+
+// invalidRestoreMessage is returned both for unknown accounts and wrong passwords, so
+// callers can't tell which accounts were deleted.
+const invalidRestoreMessage = "no deleted account matches these credentials: RestoreAccount"
+
+// RestoreAccount undoes DeleteUser for an account deleted less than purge.GracePeriod
+// ago. It needs no session, since deleting the account signed the user out.
+func (s *server) RestoreAccount(ctx context.Context, req *pb.RestoreAccountRequest) (*pb.RestoreAccountResponse, error) {
+	identifier := strings.TrimSpace(req.GetIdentifier())
+	if identifier == "" || req.GetPassword() == "" {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "identifier and password are required: RestoreAccount", nil)
+	}
+
+	err := s.limitAttempts(ctx, "restore", callerIdentifier(ctx, identifier), maxRestoresPerIdentifier, maxRestoresPerIP, "RestoreAccount")
+	if err != nil {
+		return nil, err
+	}
+
+	getDeletedUserByEmailOrUsernameParams := database.GetDeletedUserByEmailOrUsernameParams{
+		Email:    identifier,
+		Username: identifier,
+	}
+	user, err := s.db.GetDeletedUserByEmailOrUsername(ctx, getDeletedUserByEmailOrUsernameParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, invalidRestoreMessage, nil)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: RestoreAccount", err)
+	}
+
+	if err := authService.CheckPassword(user.Password, req.GetPassword()); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, invalidRestoreMessage, nil)
+	}
+
+	restoreUserParams := database.RestoreUserParams{
+		ID:           user.ID,
+		DeletedAfter: time.Now().UTC().Add(-purge.GracePeriod),
+	}
+	restored, err := s.db.RestoreUser(ctx, restoreUserParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "account was deleted too long ago to be restored: RestoreAccount", err)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't restore user in db: RestoreAccount", err)
+	}
+
+	return &pb.RestoreAccountResponse{
+		User: newPbUser(restored, viewerSelf),
+	}, nil
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/auth"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// MethodPolicies says who may call each RPC of the user service. It is enforced by
+// the interceptor in internal/auth before a request reaches its handler; an RPC
+// missing from this table can't be called at all.
+var MethodPolicies = map[string]auth.Policy{
+	fullMethod("GetUserByID"):              auth.Public,
+	fullMethod("GetUserByEmailOrUsername"): auth.Public,
+	fullMethod("GetUserByToken"):           auth.User,
+	fullMethod("GetAllUsers"):              auth.Admin,
+	fullMethod("SearchUsers"):              auth.Public,
+	fullMethod("ExportUsers"):              auth.Admin,
+
+	fullMethod("ChangeUsername"):     auth.User,
+	fullMethod("UpdateProfile"):      auth.User,
+	fullMethod("ChangePassword"):     auth.User,
+	fullMethod("SetAccountPrivacy"):  auth.User,
+	fullMethod("RequestEmailChange"): auth.User,
+	fullMethod("ConfirmEmailChange"): auth.User,
+
+	fullMethod("SubscribeUser"):        auth.User,
+	fullMethod("UnsubscribeUser"):      auth.User,
+	fullMethod("ListFollowRequests"):   auth.User,
+	fullMethod("ApproveFollowRequest"): auth.User,
+	fullMethod("RejectFollowRequest"):  auth.User,
+	fullMethod("ListFollowers"):        auth.Public,
+	fullMethod("ListFollowing"):        auth.Public,
+	fullMethod("GetFollowCounts"):      auth.Public,
+	fullMethod("IsFollowing"):          auth.Public,
+	fullMethod("GetMutualFollowers"):   auth.Public,
+	fullMethod("SuggestUsers"):         auth.User,
+
+	fullMethod("BlockUser"):        auth.User,
+	fullMethod("UnblockUser"):      auth.User,
+	fullMethod("MuteUser"):         auth.User,
+	fullMethod("ListBlockedUsers"): auth.User,
+
+	fullMethod("SendVerificationCode"):      auth.User,
+	fullMethod("SendVerificationCodeAgain"): auth.User,
+	fullMethod("SendEmailVerification"):     auth.User,
+	fullMethod("VerifyEmail"):               auth.User,
+	fullMethod("ResetPassword"):             auth.User,
+	fullMethod("RequestPasswordReset"):      auth.Public,
+	fullMethod("ConfirmPasswordReset"):      auth.Public,
+
+	fullMethod("DeleteUser"):     auth.User,
+	fullMethod("RestoreAccount"): auth.Public,
+	fullMethod("DeleteAllUsers"): auth.Admin,
+
+	fullMethod("ListSessions"):           auth.User,
+	fullMethod("RevokeSession"):          auth.User,
+	fullMethod("RevokeAllOtherSessions"): auth.User,
+
+	fullMethod("EnrollTOTP"):              auth.User,
+	fullMethod("ConfirmTOTP"):             auth.User,
+	fullMethod("DisableTOTP"):             auth.User,
+	fullMethod("RegenerateRecoveryCodes"): auth.User,
+
+	fullMethod("BeginPasskeyRegistration"):  auth.User,
+	fullMethod("FinishPasskeyRegistration"): auth.User,
+	fullMethod("BeginPasskeyAssertion"):     auth.Public,
+	fullMethod("FinishPasskeyAssertion"):    auth.Public,
+	fullMethod("ListPasskeys"):              auth.User,
+	fullMethod("RenamePasskey"):             auth.User,
+
+	fullMethod("RegisterDeviceToken"):     auth.User,
+	fullMethod("UnregisterDeviceToken"):   auth.User,
+	fullMethod("ListDeviceTokens"):        auth.User,
+	fullMethod("GetDeviceTokensForUsers"): auth.Admin,
+
+	fullMethod("RequestDataExport"):   auth.User,
+	fullMethod("GetDataExportStatus"): auth.User,
+	fullMethod("DownloadDataExport"):  auth.User,
+
+	fullMethod("GrantRole"):  auth.Admin,
+	fullMethod("RevokeRole"): auth.Admin,
+}
+
+// VerifiedMethods are the RPCs that also need the caller to have verified their
+// email, so fresh unverified accounts can't be used to spam other users.
+var VerifiedMethods = []string{
+	fullMethod("ChangeUsername"),
+	fullMethod("UpdateProfile"),
+	fullMethod("SubscribeUser"),
+}
+
+// fullMethod turns an RPC name into the full method name gRPC interceptors see.
+func fullMethod(name string) string {
+	return "/" + pb.UserService_ServiceDesc.ServiceName + "/" + name
+}
+
+// requireUserID returns the id of the authenticated caller of method. The interceptor
+// has already rejected anonymous calls to methods that need a user, so a missing
+// identity means the method's policy is wrong.
+func requireUserID(ctx context.Context, method string) (uuid.UUID, error) {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return uuid.Nil, helper.RespondWithErrorGRPC(ctx, codes.Unauthenticated, "authentication required: "+method, nil)
+	}
+	return identity.UserID, nil
+}
+
+// callerFromContext returns whoever sent the request. Anonymous callers of public
+// methods are shown public fields only.
+func callerFromContext(ctx context.Context) caller {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return caller{}
+	}
+
+	return caller{
+		id:            identity.UserID,
+		authenticated: true,
+		admin:         identity.IsAdmin(),
+	}
+}
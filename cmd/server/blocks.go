@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/rabbitmq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// Values of the user_blocks.kind column.
+const (
+	blockKindBlock = "block"
+	blockKindMute  = "mute"
+)
+
+// BlockUser blocks another user and drops any subscription or follow request between
+// the two of them. Blocking someone you muted turns the mute into a block.
+func (s *server) BlockUser(ctx context.Context, req *pb.BlockUserRequest) (*pb.BlockUserResponse, error) {
+	blockerID, blockedID, err := s.blockTarget(ctx, req.GetUserId(), "BlockUser")
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		blockUserParams := database.BlockUserParams{
+			BlockerID: blockerID,
+			BlockedID: blockedID,
+		}
+		if err := q.BlockUser(ctx, blockUserParams); err != nil {
+			return err
+		}
+
+		deleteFollowsBetweenParams := database.DeleteFollowsBetweenParams{
+			UserA: blockerID,
+			UserB: blockedID,
+		}
+		if err := q.DeleteFollowsBetween(ctx, deleteFollowsBetweenParams); err != nil {
+			return err
+		}
+
+		deleteFollowRequestsBetweenParams := database.DeleteFollowRequestsBetweenParams{
+			UserA: blockerID,
+			UserB: blockedID,
+		}
+		return q.DeleteFollowRequestsBetween(ctx, deleteFollowRequestsBetweenParams)
+	})
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't block user in db: BlockUser", err)
+	}
+
+	err = s.rabbitmq.PublishEvent(rabbitmq.Event{
+		EventType: rabbitmq.UserBlockedRoutingKey,
+		UserID:    blockerID.String(),
+		Timestamp: time.Now().UTC(),
+		Data: map[string]string{
+			"blocked_id": blockedID.String(),
+		},
+	})
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't publish message to RabbitMQ: BlockUser", err)
+	}
+
+	return &pb.BlockUserResponse{
+		Status: true,
+	}, nil
+}
+
+func (s *server) UnblockUser(ctx context.Context, req *pb.UnblockUserRequest) (*pb.UnblockUserResponse, error) {
+	blockerID, err := requireUserID(ctx, "UnblockUser")
+	if err != nil {
+		return nil, err
+	}
+
+	blockedID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user id from incoming request: UnblockUser", err)
+	}
+
+	unblockUserParams := database.UnblockUserParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	}
+
+	if err := s.db.UnblockUser(ctx, unblockUserParams); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't unblock user in db: UnblockUser", err)
+	}
+
+	return &pb.UnblockUserResponse{
+		Status: true,
+	}, nil
+}
+
+// MuteUser hides another user without telling them. Muting someone you already
+// blocked leaves the block in place.
+func (s *server) MuteUser(ctx context.Context, req *pb.MuteUserRequest) (*pb.MuteUserResponse, error) {
+	muterID, mutedID, err := s.blockTarget(ctx, req.GetUserId(), "MuteUser")
+	if err != nil {
+		return nil, err
+	}
+
+	muteUserParams := database.MuteUserParams{
+		BlockerID: muterID,
+		BlockedID: mutedID,
+	}
+
+	if err := s.db.MuteUser(ctx, muteUserParams); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't mute user in db: MuteUser", err)
+	}
+
+	return &pb.MuteUserResponse{
+		Status: true,
+	}, nil
+}
+
+func (s *server) ListBlockedUsers(ctx context.Context, req *pb.ListBlockedUsersRequest) (*pb.ListBlockedUsersResponse, error) {
+	userID, err := requireUserID(ctx, "ListBlockedUsers")
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := helper.DecodeKeysetToken(req.GetPageToken())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "invalid page token: ListBlockedUsers", err)
+	}
+	cursorCreatedAt, cursorID := keysetArgs(cursor)
+	pageSize := helper.PageSize(req.GetPageSize())
+
+	listBlockedUsersParams := database.ListBlockedUsersParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        pageSize + 1,
+	}
+
+	rows, err := s.db.ListBlockedUsers(ctx, listBlockedUsersParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get blocked users from db: ListBlockedUsers", err)
+	}
+
+	nextPageToken := ""
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		nextPageToken = helper.EncodeKeysetToken(last.BlockedAt, last.User.ID)
+	}
+
+	blockedUsers := make([]*pb.BlockedUser, len(rows))
+	for i, row := range rows {
+		blockedUsers[i] = &pb.BlockedUser{
+			User:      newPbPublicUser(row.User),
+			Kind:      pbBlockKind(row.Kind),
+			BlockedAt: timestamppb.New(row.BlockedAt),
+		}
+	}
+
+	return &pb.ListBlockedUsersResponse{
+		BlockedUsers:  blockedUsers,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+// blockTarget resolves the user the caller of method wants to block or mute, rejecting
+// the caller themselves and users that don't exist.
+func (s *server) blockTarget(ctx context.Context, rawTargetID, method string) (uuid.UUID, uuid.UUID, error) {
+	callerID, err := requireUserID(ctx, method)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	targetID, err := uuid.Parse(rawTargetID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user id from incoming request: "+method, err)
+	}
+
+	if callerID == targetID {
+		return uuid.Nil, uuid.Nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "you can't block or mute yourself: "+method, nil)
+	}
+
+	if _, err := s.db.GetUserById(ctx, targetID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, uuid.Nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "user not found: "+method, err)
+		}
+		return uuid.Nil, uuid.Nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: "+method, err)
+	}
+
+	return callerID, targetID, nil
+}
+
+func pbBlockKind(kind string) pb.BlockKind {
+	if kind == blockKindMute {
+		return pb.BlockKind_MUTE
+	}
+	return pb.BlockKind_BLOCK
+}
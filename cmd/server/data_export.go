@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/dataexport"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// downloadChunkSize is how many bytes of an archive each DownloadDataExport message
+// carries.
+const downloadChunkSize = 64 * 1024
+
+// RequestDataExport asks for an archive of everything the service holds about the
+// caller. The archive is built in the background; GetDataExportStatus tells when it
+// is ready. An export still being built, or built less than
+// dataexport.RequestCooldown ago, is returned instead of starting a new one.
+func (s *server) RequestDataExport(ctx context.Context, req *pb.RequestDataExportRequest) (*pb.RequestDataExportResponse, error) {
+	userID, err := requireUserID(ctx, "RequestDataExport")
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := s.db.GetLatestDataExport(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get data export from db: RequestDataExport", err)
+	}
+	if err == nil && reusableDataExport(latest, time.Now().UTC()) {
+		return &pb.RequestDataExportResponse{Export: newPbDataExport(latest)}, nil
+	}
+
+	createDataExportParams := database.CreateDataExportParams{
+		ID:        uuid.New(),
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+	}
+	export, err := s.db.CreateDataExport(ctx, createDataExportParams)
+	if helper.IsUniqueViolation(err) {
+		// A concurrent request started an export first.
+		export, err = s.db.GetLatestDataExport(ctx, userID)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't create data export in db: RequestDataExport", err)
+	}
+
+	s.dataExports.Notify()
+
+	return &pb.RequestDataExportResponse{
+		Export: newPbDataExport(export),
+	}, nil
+}
+
+// reusableDataExport reports whether export answers a new request made at now.
+func reusableDataExport(export database.DataExport, now time.Time) bool {
+	switch export.Status {
+	case dataexport.StatusPending, dataexport.StatusRunning:
+		return true
+	case dataexport.StatusReady:
+		return now.Before(export.CreatedAt.Add(dataexport.RequestCooldown))
+	default:
+		return false
+	}
+}
+
+// GetDataExportStatus returns an export of the caller.
+func (s *server) GetDataExportStatus(ctx context.Context, req *pb.GetDataExportStatusRequest) (*pb.GetDataExportStatusResponse, error) {
+	userID, err := requireUserID(ctx, "GetDataExportStatus")
+	if err != nil {
+		return nil, err
+	}
+
+	export, err := s.dataExport(ctx, userID, req.GetExportId(), "GetDataExportStatus")
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetDataExportStatusResponse{
+		Export: newPbDataExport(export),
+	}, nil
+}
+
+// DownloadDataExport streams the zip archive of a ready export of the caller in chunks
+// of downloadChunkSize bytes.
+func (s *server) DownloadDataExport(req *pb.DownloadDataExportRequest, stream pb.UserService_DownloadDataExportServer) error {
+	ctx := stream.Context()
+
+	userID, err := requireUserID(ctx, "DownloadDataExport")
+	if err != nil {
+		return err
+	}
+
+	export, err := s.dataExport(ctx, userID, req.GetExportId(), "DownloadDataExport")
+	if err != nil {
+		return err
+	}
+
+	if export.Status != dataexport.StatusReady {
+		return helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "data export is not ready: DownloadDataExport", nil)
+	}
+	if !time.Now().UTC().Before(export.ExpiresAt.Time) {
+		return helper.RespondWithErrorGRPC(ctx, codes.NotFound, "data export expired: DownloadDataExport", nil)
+	}
+
+	return s.sendDataExport(stream, export)
+}
+
+// sendDataExport reads the archive of export a chunk at a time and sends each chunk
+// as soon as it is read, stopping as soon as the client goes away.
+func (s *server) sendDataExport(stream pb.UserService_DownloadDataExportServer, export database.DataExport) error {
+	ctx := stream.Context()
+	for start := int64(0); start < export.SizeBytes; start += downloadChunkSize {
+		if err := ctx.Err(); err != nil {
+			return helper.RespondWithErrorGRPC(ctx, status.FromContextError(err).Code(), "download stopped: DownloadDataExport", err)
+		}
+
+		readDataExportChunkParams := database.ReadDataExportChunkParams{
+			ChunkStart: int32(start + 1),
+			ChunkSize:  downloadChunkSize,
+			ExportID:   export.ID,
+		}
+		chunk, err := s.db.ReadDataExportChunk(ctx, readDataExportChunkParams)
+		if errors.Is(err, sql.ErrNoRows) {
+			return helper.RespondWithErrorGRPC(ctx, codes.NotFound, "data export expired: DownloadDataExport", err)
+		}
+		if err != nil {
+			return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't read data export from db: DownloadDataExport", err)
+		}
+
+		if err := stream.Send(&pb.DownloadDataExportResponse{Chunk: chunk}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dataExport finds the export with the given id among the exports of userID.
+func (s *server) dataExport(ctx context.Context, userID uuid.UUID, rawID, method string) (database.DataExport, error) {
+	exportID, err := uuid.Parse(rawID)
+	if err != nil {
+		return database.DataExport{}, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse export id: "+method, err)
+	}
+
+	getDataExportParams := database.GetDataExportParams{
+		ID:     exportID,
+		UserID: userID,
+	}
+	export, err := s.db.GetDataExport(ctx, getDataExportParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.DataExport{}, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "data export not found: "+method, err)
+	}
+	if err != nil {
+		return database.DataExport{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get data export from db: "+method, err)
+	}
+
+	return export, nil
+}
+
+func newPbDataExport(export database.DataExport) *pb.DataExport {
+	pbExport := &pb.DataExport{
+		Id:          export.ID.String(),
+		Status:      dataExportStatusToPb(export.Status),
+		RequestedAt: timestamppb.New(export.CreatedAt),
+		SizeBytes:   export.SizeBytes,
+	}
+	if export.CompletedAt.Valid {
+		pbExport.CompletedAt = timestamppb.New(export.CompletedAt.Time)
+	}
+	if export.ExpiresAt.Valid {
+		pbExport.ExpiresAt = timestamppb.New(export.ExpiresAt.Time)
+	}
+	return pbExport
+}
+
+func dataExportStatusToPb(exportStatus string) pb.DataExportStatus {
+	switch exportStatus {
+	case dataexport.StatusRunning:
+		return pb.DataExportStatus_RUNNING
+	case dataexport.StatusReady:
+		return pb.DataExportStatus_READY
+	case dataexport.StatusFailed:
+		return pb.DataExportStatus_FAILED
+	default:
+		return pb.DataExportStatus_PENDING
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+const (
+	// maxDeviceTokenLength is longer than the tokens FCM, APNs and Web Push hand out,
+	// and short enough for the unique btree index on device_token.
+	maxDeviceTokenLength = 2048
+	// maxDeviceTokenUsers is how many users GetDeviceTokensForUsers takes at a time.
+	maxDeviceTokenUsers = 500
+)
+
+// Device types, as stored in device_tokens.device_type.
+const (
+	deviceTypeAndroid = "android"
+	deviceTypeIOS     = "ios"
+	deviceTypeWeb     = "web"
+)
+
+// RegisterDeviceToken adds a push notification token of the caller, or marks it as
+// seen if it is already registered. A token registered by another user before moves
+// to the caller, since only one user is signed in on a device at a time.
+func (s *server) RegisterDeviceToken(ctx context.Context, req *pb.RegisterDeviceTokenRequest) (*pb.RegisterDeviceTokenResponse, error) {
+	userID, err := requireUserID(ctx, "RegisterDeviceToken")
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := parseDeviceToken(ctx, req.GetDeviceToken(), "RegisterDeviceToken")
+	if err != nil {
+		return nil, err
+	}
+
+	deviceType, ok := deviceTypeFromPb(req.GetDeviceType())
+	if !ok {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "device type must be ANDROID, IOS or WEB: RegisterDeviceToken", nil)
+	}
+
+	registerDeviceTokenParams := database.RegisterDeviceTokenParams{
+		ID:          uuid.New(),
+		UserID:      userID,
+		DeviceToken: token,
+		DeviceType:  deviceType,
+		Now:         time.Now().UTC(),
+	}
+	deviceToken, err := s.db.RegisterDeviceToken(ctx, registerDeviceTokenParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't register device token in db: RegisterDeviceToken", err)
+	}
+
+	return &pb.RegisterDeviceTokenResponse{
+		DeviceToken: newPbDeviceToken(deviceToken),
+	}, nil
+}
+
+// UnregisterDeviceToken removes a push notification token of the caller, e.g. when
+// they sign out. Removing a token that isn't registered succeeds too.
+func (s *server) UnregisterDeviceToken(ctx context.Context, req *pb.UnregisterDeviceTokenRequest) (*pb.UnregisterDeviceTokenResponse, error) {
+	userID, err := requireUserID(ctx, "UnregisterDeviceToken")
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := parseDeviceToken(ctx, req.GetDeviceToken(), "UnregisterDeviceToken")
+	if err != nil {
+		return nil, err
+	}
+
+	unregisterDeviceTokenParams := database.UnregisterDeviceTokenParams{
+		UserID:      userID,
+		DeviceToken: token,
+	}
+	if err := s.db.UnregisterDeviceToken(ctx, unregisterDeviceTokenParams); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't unregister device token in db: UnregisterDeviceToken", err)
+	}
+
+	return &pb.UnregisterDeviceTokenResponse{
+		Status: true,
+	}, nil
+}
+
+// ListDeviceTokens returns the push notification tokens of the caller, most recently
+// seen first.
+func (s *server) ListDeviceTokens(ctx context.Context, req *pb.ListDeviceTokensRequest) (*pb.ListDeviceTokensResponse, error) {
+	userID, err := requireUserID(ctx, "ListDeviceTokens")
+	if err != nil {
+		return nil, err
+	}
+
+	deviceTokens, err := s.db.ListDeviceTokens(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't list device tokens from db: ListDeviceTokens", err)
+	}
+
+	return &pb.ListDeviceTokensResponse{
+		DeviceTokens: newPbDeviceTokens(deviceTokens),
+	}, nil
+}
+
+// GetDeviceTokensForUsers returns the push notification tokens of up to
+// maxDeviceTokenUsers users at once, for the notification service. Unknown and
+// deleted users have no tokens.
+func (s *server) GetDeviceTokensForUsers(ctx context.Context, req *pb.GetDeviceTokensForUsersRequest) (*pb.GetDeviceTokensForUsersResponse, error) {
+	if len(req.GetUserIds()) > maxDeviceTokenUsers {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "too many user ids: GetDeviceTokensForUsers", nil)
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(req.GetUserIds()))
+	for _, rawID := range req.GetUserIds() {
+		userID, err := uuid.Parse(rawID)
+		if err != nil {
+			return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user id from incoming request: GetDeviceTokensForUsers", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	deviceTokens, err := s.db.GetDeviceTokensForUsers(ctx, userIDs)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get device tokens from db: GetDeviceTokensForUsers", err)
+	}
+
+	return &pb.GetDeviceTokensForUsersResponse{
+		DeviceTokens: newPbDeviceTokens(deviceTokens),
+	}, nil
+}
+
+// parseDeviceToken trims a token sent by a client and checks its length.
+func parseDeviceToken(ctx context.Context, raw, method string) (string, error) {
+	token := strings.TrimSpace(raw)
+	if token == "" {
+		return "", helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "device token is required: "+method, nil)
+	}
+	if len(token) > maxDeviceTokenLength {
+		return "", helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "device token is too long: "+method, nil)
+	}
+	return token, nil
+}
+
+func deviceTypeFromPb(deviceType pb.DeviceType) (string, bool) {
+	switch deviceType {
+	case pb.DeviceType_ANDROID:
+		return deviceTypeAndroid, true
+	case pb.DeviceType_IOS:
+		return deviceTypeIOS, true
+	case pb.DeviceType_WEB:
+		return deviceTypeWeb, true
+	default:
+		return "", false
+	}
+}
+
+func deviceTypeToPb(deviceType string) pb.DeviceType {
+	switch deviceType {
+	case deviceTypeAndroid:
+		return pb.DeviceType_ANDROID
+	case deviceTypeIOS:
+		return pb.DeviceType_IOS
+	case deviceTypeWeb:
+		return pb.DeviceType_WEB
+	default:
+		return pb.DeviceType_DEVICE_TYPE_UNSPECIFIED
+	}
+}
+
+func newPbDeviceToken(deviceToken database.DeviceToken) *pb.DeviceToken {
+	return &pb.DeviceToken{
+		Id:          deviceToken.ID.String(),
+		UserId:      deviceToken.UserID.String(),
+		DeviceToken: deviceToken.DeviceToken,
+		DeviceType:  deviceTypeToPb(deviceToken.DeviceType),
+		CreatedAt:   timestamppb.New(deviceToken.CreatedAt),
+		LastSeenAt:  timestamppb.New(deviceToken.LastSeenAt),
+	}
+}
+
+func newPbDeviceTokens(deviceTokens []database.DeviceToken) []*pb.DeviceToken {
+	pbDeviceTokens := make([]*pb.DeviceToken, 0, len(deviceTokens))
+	for _, deviceToken := range deviceTokens {
+		pbDeviceTokens = append(pbDeviceTokens, newPbDeviceToken(deviceToken))
+	}
+	return pbDeviceTokens
+}
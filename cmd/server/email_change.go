@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	authService "github.com/imhasandl/auth-service/cmd/auth"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/mail"
+	"github.com/imhasandl/user-service/internal/rabbitmq"
+	"github.com/imhasandl/user-service/internal/verification"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// Errors of swapEmail.
+var (
+	// errEmailChangeReplaced is returned when a newer RequestEmailChange replaced the
+	// request being confirmed.
+	errEmailChangeReplaced = errors.New("email change request was replaced by a newer one")
+	errEmailTaken          = errors.New("email is already in use")
+)
+
+// RequestEmailChange sends a confirmation code to the new address and a notice to the
+// old one. The email only changes once the code comes back through ConfirmEmailChange.
+// It answers the same way whether or not the new address is taken, so it can't be used
+// to find out who has an account. The code goes to the owner of the address, so the
+// caller only learns it is taken if they own it.
+func (s *server) RequestEmailChange(ctx context.Context, req *pb.RequestEmailChangeRequest) (*pb.RequestEmailChangeResponse, error) {
+	userID, err := requireUserID(ctx, "RequestEmailChange")
+	if err != nil {
+		return nil, err
+	}
+
+	user, newEmail, err := s.emailChangeTarget(ctx, userID, req.GetNewEmail())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.canIssueEmailChange(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.requireSecondFactor(ctx, userID, req.GetSecondFactor(), "RequestEmailChange"); err != nil {
+		return nil, err
+	}
+
+	if err := s.issueEmailChange(ctx, user, newEmail); err != nil {
+		return nil, err
+	}
+
+	err = s.rabbitmq.PublishEvent(rabbitmq.Event{
+		EventType: rabbitmq.UserEmailChangeRequestedRoutingKey,
+		UserID:    userID.String(),
+		Timestamp: time.Now().UTC(),
+		Data: map[string]string{
+			"old_email": user.Email,
+			"new_email": newEmail,
+		},
+	})
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't publish message to RabbitMQ: RequestEmailChange", err)
+	}
+
+	return &pb.RequestEmailChangeResponse{
+		Status: "Confirmation code sent to the new email",
+	}, nil
+}
+
+// ConfirmEmailChange swaps the email of the caller for the one the code was sent to.
+func (s *server) ConfirmEmailChange(ctx context.Context, req *pb.ConfirmEmailChangeRequest) (*pb.ConfirmEmailChangeResponse, error) {
+	userID, err := requireUserID(ctx, "ConfirmEmailChange")
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := s.checkEmailChangeCode(ctx, userID, req.GetCode())
+	if err != nil {
+		return nil, err
+	}
+
+	oldUser, err := s.db.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: ConfirmEmailChange", err)
+	}
+
+	user, err := s.swapEmail(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.rabbitmq.PublishEvent(rabbitmq.Event{
+		EventType: rabbitmq.UserEmailChangedRoutingKey,
+		UserID:    userID.String(),
+		Timestamp: time.Now().UTC(),
+		Data: map[string]string{
+			"old_email": oldUser.Email,
+			"new_email": user.Email,
+		},
+	})
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't publish message to RabbitMQ: ConfirmEmailChange", err)
+	}
+
+	return &pb.ConfirmEmailChangeResponse{
+		User: newPbUser(user, viewerSelf),
+	}, nil
+}
+
+// emailChangeTarget validates rawEmail as the next email of the user with userID.
+func (s *server) emailChangeTarget(ctx context.Context, userID uuid.UUID, rawEmail string) (database.User, string, error) {
+	address, err := netmail.ParseAddress(strings.TrimSpace(rawEmail))
+	if err != nil || address.Name != "" {
+		return database.User{}, "", helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "new email is not a valid address: RequestEmailChange", err)
+	}
+	newEmail := address.Address
+
+	user, err := s.db.GetUserById(ctx, userID)
+	if err != nil {
+		return database.User{}, "", helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: RequestEmailChange", err)
+	}
+
+	if strings.EqualFold(newEmail, user.Email) {
+		return database.User{}, "", helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "new email is the current email: RequestEmailChange", nil)
+	}
+
+	return user, newEmail, nil
+}
+
+// canIssueEmailChange applies the limits of verification codes to a new email change
+// of userID: the resend cooldown and the lock after too many wrong codes.
+func (s *server) canIssueEmailChange(ctx context.Context, userID uuid.UUID) error {
+	pending, err := s.db.GetEmailChangeRequest(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get email change request: RequestEmailChange", err)
+	}
+	if err := verification.CanIssue(emailChangeState(pending), time.Now().UTC()); err != nil {
+		return verificationError(ctx, err, "RequestEmailChange")
+	}
+	return nil
+}
+
+// issueEmailChange replaces any pending email change of user, tells the current
+// address about it and emails a code to newEmail. Callers check canIssueEmailChange
+// first.
+func (s *server) issueEmailChange(ctx context.Context, user database.User, newEmail string) error {
+	now := time.Now().UTC()
+
+	code, err := authService.GenerateVerificationCode()
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't generate verification code: RequestEmailChange", err)
+	}
+
+	upsertEmailChangeRequestParams := database.UpsertEmailChangeRequestParams{
+		UserID:    user.ID,
+		NewEmail:  newEmail,
+		CodeHash:  s.verifier.Hash(user.ID, code),
+		ExpiresAt: now.Add(verification.CodeTTL),
+		CreatedAt: now,
+	}
+	if err := s.db.UpsertEmailChangeRequest(ctx, upsertEmailChangeRequestParams); err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't store email change request: RequestEmailChange", err)
+	}
+
+	// The notice goes first, so the owner hears of the request even if the code can't
+	// be delivered to newEmail.
+	err = s.sendMail(ctx, user.Email, mail.TemplateEmailChangeNotice, codeMail{NewEmail: newEmail})
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't send email change notice: RequestEmailChange", err)
+	}
+
+	err = s.sendMail(ctx, newEmail, mail.TemplateEmailChangeCode, codeMail{Code: formatCode(code), NewEmail: newEmail})
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't send verification email: RequestEmailChange", err)
+	}
+
+	return nil
+}
+
+// checkEmailChangeCode counts an attempt at the pending email change of userID and
+// checks code against it.
+func (s *server) checkEmailChangeCode(ctx context.Context, userID uuid.UUID, code int32) (database.EmailChangeRequest, error) {
+	request, err := s.db.GetEmailChangeRequest(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.EmailChangeRequest{}, verificationError(ctx, verification.ErrNoCode, "ConfirmEmailChange")
+	}
+	if err != nil {
+		return database.EmailChangeRequest{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get email change request: ConfirmEmailChange", err)
+	}
+
+	attempts, err := s.db.RecordEmailChangeAttempt(ctx, userID)
+	if err != nil {
+		return database.EmailChangeRequest{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't record verification attempt: ConfirmEmailChange", err)
+	}
+
+	err = s.verifier.Check(emailChangeState(request), userID, code, attempts, time.Now().UTC())
+	if err != nil {
+		return database.EmailChangeRequest{}, verificationError(ctx, err, "ConfirmEmailChange")
+	}
+
+	return request, nil
+}
+
+// swapEmail sets the new email of request and deletes the request in one transaction.
+// The code proved the user owns the address, so it counts as verified. If an account
+// already uses the address, whatever its letter case, nothing changes. The unique
+// constraint on users.email catches one that takes it while the swap runs.
+func (s *server) swapEmail(ctx context.Context, request database.EmailChangeRequest) (database.User, error) {
+	var user database.User
+	err := s.withTx(ctx, func(q *database.Queries) error {
+		deleteEmailChangeRequestParams := database.DeleteEmailChangeRequestParams{
+			UserID:   request.UserID,
+			CodeHash: request.CodeHash,
+		}
+		deleted, err := q.DeleteEmailChangeRequest(ctx, deleteEmailChangeRequestParams)
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return errEmailChangeReplaced
+		}
+
+		taken, err := q.IsEmailTaken(ctx, request.NewEmail)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return errEmailTaken
+		}
+
+		changeEmailParams := database.ChangeEmailParams{
+			ID:         request.UserID,
+			Email:      request.NewEmail,
+			VerifiedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		}
+		user, err = q.ChangeEmail(ctx, changeEmailParams)
+		return err
+	})
+
+	switch {
+	case err == nil:
+		return user, nil
+	case errors.Is(err, errEmailTaken), helper.IsUniqueViolation(err):
+		return database.User{}, helper.RespondWithErrorGRPC(ctx, codes.AlreadyExists, "email is already in use: ConfirmEmailChange", err)
+	case errors.Is(err, errEmailChangeReplaced):
+		return database.User{}, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, err.Error()+": ConfirmEmailChange", err)
+	default:
+		return database.User{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't change email in db: ConfirmEmailChange", err)
+	}
+}
+
+func emailChangeState(request database.EmailChangeRequest) verification.State {
+	return verification.State{
+		Hash:      request.CodeHash,
+		ExpiresAt: request.ExpiresAt,
+		Attempts:  request.Attempts,
+		SentAt:    request.CreatedAt,
+	}
+}
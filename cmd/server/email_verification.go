@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	authService "github.com/imhasandl/auth-service/cmd/auth"
+	"github.com/imhasandl/user-service/internal/auth"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/mail"
+	"github.com/imhasandl/user-service/internal/verification"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// NewVerifiedResolver tells the auth interceptor whether the caller of a method in
+// VerifiedMethods has verified their email.
+func NewVerifiedResolver(db *database.Queries) auth.VerifiedResolver {
+	return func(ctx context.Context, userID uuid.UUID) (bool, error) {
+		verified, err := db.IsUserVerified(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return verified, err
+	}
+}
+
+// SendEmailVerification emails the caller a code that proves they own their address.
+// The limits of verification codes apply. The code has its own slot, so it can't reset
+// the password and doesn't cancel a pending password reset code.
+func (s *server) SendEmailVerification(
+	ctx context.Context,
+	req *pb.SendEmailVerificationRequest,
+) (*pb.SendEmailVerificationResponse, error) {
+	userID, err := requireUserID(ctx, "SendEmailVerification")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: SendEmailVerification", err)
+	}
+
+	if user.IsVerified {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "email is already verified: SendEmailVerification", nil)
+	}
+
+	if err := s.issueEmailVerification(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &pb.SendEmailVerificationResponse{
+		Status: "Verification code sent",
+	}, nil
+}
+
+// VerifyEmail marks the caller's email as verified once they send back the code.
+func (s *server) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (*pb.VerifyEmailResponse, error) {
+	userID, err := requireUserID(ctx, "VerifyEmail")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: VerifyEmail", err)
+	}
+
+	if user.IsVerified {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "email is already verified: VerifyEmail", nil)
+	}
+
+	if err := s.checkEmailVerificationCode(ctx, userID, req.GetVerificationCode()); err != nil {
+		return nil, err
+	}
+
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		if err := q.DeleteEmailVerification(ctx, userID); err != nil {
+			return err
+		}
+
+		markEmailVerifiedParams := database.MarkEmailVerifiedParams{
+			ID:         userID,
+			VerifiedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		}
+		user, err = q.MarkEmailVerified(ctx, markEmailVerifiedParams)
+		return err
+	})
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't mark email as verified: VerifyEmail", err)
+	}
+
+	return &pb.VerifyEmailResponse{
+		User: newPbUser(user, viewerSelf),
+	}, nil
+}
+
+// issueEmailVerification emails user a new email verification code and stores its
+// hash, unless the flow is locked or a code was sent too recently.
+func (s *server) issueEmailVerification(ctx context.Context, user database.User) error {
+	now := time.Now().UTC()
+
+	pending, err := s.db.GetEmailVerification(ctx, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get email verification: SendEmailVerification", err)
+	}
+	if err := verification.CanIssue(emailVerificationState(pending), now); err != nil {
+		return verificationError(ctx, err, "SendEmailVerification")
+	}
+
+	code, err := authService.GenerateVerificationCode()
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't generate verification code: SendEmailVerification", err)
+	}
+
+	storeEmailVerificationParams := database.StoreEmailVerificationParams{
+		UserID:     user.ID,
+		CodeHash:   s.verifier.Hash(user.ID, code),
+		ExpiresAt:  now.Add(verification.CodeTTL),
+		SentAt:     now,
+		SentBefore: now.Add(-verification.ResendCooldown),
+	}
+	stored, err := s.db.StoreEmailVerification(ctx, storeEmailVerificationParams)
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't store email verification: SendEmailVerification", err)
+	}
+	if stored == 0 {
+		return verificationError(ctx, verification.ErrCooldown, "SendEmailVerification")
+	}
+
+	err = s.sendMail(ctx, user.Email, mail.TemplateEmailVerification, codeMail{Code: formatCode(code)})
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't send verification email: SendEmailVerification", err)
+	}
+
+	return nil
+}
+
+// checkEmailVerificationCode counts an attempt at the pending email verification of
+// userID and checks code against it.
+func (s *server) checkEmailVerificationCode(ctx context.Context, userID uuid.UUID, code int32) error {
+	pending, err := s.db.GetEmailVerification(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return verificationError(ctx, verification.ErrNoCode, "VerifyEmail")
+	}
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get email verification: VerifyEmail", err)
+	}
+
+	attempts, err := s.db.RecordEmailVerificationAttempt(ctx, userID)
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't record verification attempt: VerifyEmail", err)
+	}
+
+	err = s.verifier.Check(emailVerificationState(pending), userID, code, attempts, time.Now().UTC())
+	if err != nil {
+		return verificationError(ctx, err, "VerifyEmail")
+	}
+
+	return nil
+}
+
+func emailVerificationState(pending database.EmailVerification) verification.State {
+	return verification.State{
+		Hash:      pending.CodeHash,
+		ExpiresAt: pending.ExpiresAt,
+		Attempts:  pending.Attempts,
+		SentAt:    pending.SentAt,
+	}
+}
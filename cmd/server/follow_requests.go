@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// SetAccountPrivacy makes the caller's account private or public. Going public
+// approves every pending follow request, since anyone may now follow the account.
+func (s *server) SetAccountPrivacy(ctx context.Context, req *pb.SetAccountPrivacyRequest) (*pb.SetAccountPrivacyResponse, error) {
+	userID, err := requireUserID(ctx, "SetAccountPrivacy")
+	if err != nil {
+		return nil, err
+	}
+
+	var user database.User
+	var approvedIDs []uuid.UUID
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		setAccountPrivacyParams := database.SetAccountPrivacyParams{
+			ID:        userID,
+			IsPrivate: req.GetIsPrivate(),
+		}
+
+		var err error
+		user, err = q.SetAccountPrivacy(ctx, setAccountPrivacyParams)
+		if err != nil || user.IsPrivate {
+			return err
+		}
+
+		approvedIDs, err = q.AcceptAllFollowRequests(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't change account privacy in db: SetAccountPrivacy", err)
+	}
+
+	for _, approvedID := range approvedIDs {
+		if err := s.publishFollowRequestApproved(userID, approvedID); err != nil {
+			return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't publish message to RabbitMQ: SetAccountPrivacy", err)
+		}
+	}
+
+	return &pb.SetAccountPrivacyResponse{
+		User: newPbUser(user, viewerSelf),
+	}, nil
+}
+
+func (s *server) ListFollowRequests(ctx context.Context, req *pb.ListFollowRequestsRequest) (*pb.ListFollowRequestsResponse, error) {
+	userID, err := requireUserID(ctx, "ListFollowRequests")
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := helper.DecodeKeysetToken(req.GetPageToken())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "invalid page token: ListFollowRequests", err)
+	}
+	cursorCreatedAt, cursorID := keysetArgs(cursor)
+	pageSize := helper.PageSize(req.GetPageSize())
+
+	listFollowRequestsParams := database.ListFollowRequestsParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        pageSize + 1,
+	}
+
+	rows, err := s.db.ListFollowRequests(ctx, listFollowRequestsParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get follow requests from db: ListFollowRequests", err)
+	}
+
+	nextPageToken := ""
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		nextPageToken = helper.EncodeKeysetToken(last.RequestedAt, last.User.ID)
+	}
+
+	followRequests := make([]*pb.FollowRequest, len(rows))
+	for i, row := range rows {
+		followRequests[i] = &pb.FollowRequest{
+			User:        newPbPublicUser(row.User),
+			RequestedAt: timestamppb.New(row.RequestedAt),
+		}
+	}
+
+	return &pb.ListFollowRequestsResponse{
+		FollowRequests: followRequests,
+		NextPageToken:  nextPageToken,
+	}, nil
+}
+
+func (s *server) ApproveFollowRequest(
+	ctx context.Context,
+	req *pb.ApproveFollowRequestRequest,
+) (*pb.ApproveFollowRequestResponse, error) {
+	targetID, requesterID, err := s.followRequestParties(ctx, req.GetUserId(), "ApproveFollowRequest")
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		deleteFollowRequestParams := database.DeleteFollowRequestParams{
+			RequesterID: requesterID,
+			TargetID:    targetID,
+		}
+
+		deleted, err := q.DeleteFollowRequest(ctx, deleteFollowRequestParams)
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return sql.ErrNoRows
+		}
+
+		followUserParams := database.FollowUserParams{
+			FollowerID: requesterID,
+			FolloweeID: targetID,
+		}
+
+		_, err = q.FollowUser(ctx, followUserParams)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "follow request not found: ApproveFollowRequest", err)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't approve follow request in db: ApproveFollowRequest", err)
+	}
+
+	if err := s.publishFollowRequestApproved(targetID, requesterID); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't publish message to RabbitMQ: ApproveFollowRequest", err)
+	}
+
+	return &pb.ApproveFollowRequestResponse{
+		Status: true,
+	}, nil
+}
+
+func (s *server) RejectFollowRequest(
+	ctx context.Context,
+	req *pb.RejectFollowRequestRequest,
+) (*pb.RejectFollowRequestResponse, error) {
+	targetID, requesterID, err := s.followRequestParties(ctx, req.GetUserId(), "RejectFollowRequest")
+	if err != nil {
+		return nil, err
+	}
+
+	deleteFollowRequestParams := database.DeleteFollowRequestParams{
+		RequesterID: requesterID,
+		TargetID:    targetID,
+	}
+
+	deleted, err := s.db.DeleteFollowRequest(ctx, deleteFollowRequestParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't reject follow request in db: RejectFollowRequest", err)
+	}
+	if deleted == 0 {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "follow request not found: RejectFollowRequest", nil)
+	}
+
+	content := fmt.Sprintf("This user %v declined your follow request", targetID)
+	if err := s.publishNotification(targetID, requesterID, content); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't publish message to RabbitMQ: RejectFollowRequest", err)
+	}
+
+	return &pb.RejectFollowRequestResponse{
+		Status: true,
+	}, nil
+}
+
+// requestToFollow records a request from requesterID to follow the private account
+// targetID and tells its owner about it. Asking again while a request is pending,
+// or while already following, changes nothing.
+func (s *server) requestToFollow(ctx context.Context, requesterID, targetID uuid.UUID) (*pb.SubscribeUserResponse, error) {
+	getFollowParams := database.GetFollowParams{
+		FollowerID: requesterID,
+		FolloweeID: targetID,
+	}
+
+	_, err := s.db.GetFollow(ctx, getFollowParams)
+	if err == nil {
+		return &pb.SubscribeUserResponse{
+			Status: true,
+		}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get follow from db - SubscribeUser", err)
+	}
+
+	createFollowRequestParams := database.CreateFollowRequestParams{
+		RequesterID: requesterID,
+		TargetID:    targetID,
+	}
+
+	created, err := s.db.CreateFollowRequest(ctx, createFollowRequestParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't create follow request - SubscribeUser", err)
+	}
+
+	if created > 0 {
+		content := fmt.Sprintf("This user %v asked to subscribe on you", requesterID)
+		if err := s.publishNotification(requesterID, targetID, content); err != nil {
+			return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't publish message to RabbitMQ - SubscribeUser", err)
+		}
+	}
+
+	return &pb.SubscribeUserResponse{
+		Status:  true,
+		Pending: true,
+	}, nil
+}
+
+// followRequestParties returns the owner of a private account answering a follow
+// request in method, and the id of the user who sent it.
+func (s *server) followRequestParties(ctx context.Context, rawRequesterID, method string) (uuid.UUID, uuid.UUID, error) {
+	targetID, err := requireUserID(ctx, method)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	requesterID, err := uuid.Parse(rawRequesterID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user id from incoming request: "+method, err)
+	}
+
+	return targetID, requesterID, nil
+}
+
+// publishFollowRequestApproved tells requesterID that targetID accepted their follow request.
+func (s *server) publishFollowRequestApproved(targetID, requesterID uuid.UUID) error {
+	content := fmt.Sprintf("This user %v approved your follow request", targetID)
+	return s.publishNotification(targetID, requesterID, content)
+}
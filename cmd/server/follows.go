@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+func (s *server) ListFollowers(ctx context.Context, req *pb.ListFollowersRequest) (*pb.ListFollowersResponse, error) {
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user id from incoming request: ListFollowers", err)
+	}
+
+	if err := s.requireFollowGraphAccess(ctx, userID, "ListFollowers"); err != nil {
+		return nil, err
+	}
+
+	cursor, err := helper.DecodeKeysetToken(req.GetPageToken())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "invalid page token: ListFollowers", err)
+	}
+	cursorCreatedAt, cursorID := keysetArgs(cursor)
+	pageSize := helper.PageSize(req.GetPageSize())
+
+	listFollowersParams := database.ListFollowersParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        pageSize + 1,
+	}
+
+	rows, err := s.db.ListFollowers(ctx, listFollowersParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get followers from db: ListFollowers", err)
+	}
+
+	nextPageToken := ""
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		nextPageToken = helper.EncodeKeysetToken(last.FollowedAt, last.User.ID)
+	}
+
+	followers := make([]*pb.Follow, len(rows))
+	for i, row := range rows {
+		followers[i] = newPbFollow(row.User, row.FollowedAt)
+	}
+
+	return &pb.ListFollowersResponse{
+		Followers:     followers,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+func (s *server) ListFollowing(ctx context.Context, req *pb.ListFollowingRequest) (*pb.ListFollowingResponse, error) {
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user id from incoming request: ListFollowing", err)
+	}
+
+	if err := s.requireFollowGraphAccess(ctx, userID, "ListFollowing"); err != nil {
+		return nil, err
+	}
+
+	cursor, err := helper.DecodeKeysetToken(req.GetPageToken())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "invalid page token: ListFollowing", err)
+	}
+	cursorCreatedAt, cursorID := keysetArgs(cursor)
+	pageSize := helper.PageSize(req.GetPageSize())
+
+	listFollowingParams := database.ListFollowingParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        pageSize + 1,
+	}
+
+	rows, err := s.db.ListFollowing(ctx, listFollowingParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get followed users from db: ListFollowing", err)
+	}
+
+	nextPageToken := ""
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		nextPageToken = helper.EncodeKeysetToken(last.FollowedAt, last.User.ID)
+	}
+
+	following := make([]*pb.Follow, len(rows))
+	for i, row := range rows {
+		following[i] = newPbFollow(row.User, row.FollowedAt)
+	}
+
+	return &pb.ListFollowingResponse{
+		Following:     following,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+func (s *server) GetFollowCounts(ctx context.Context, req *pb.GetFollowCountsRequest) (*pb.GetFollowCountsResponse, error) {
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user id from incoming request: GetFollowCounts", err)
+	}
+
+	counts, err := s.db.GetFollowCounts(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't count follows in db: GetFollowCounts", err)
+	}
+
+	return &pb.GetFollowCountsResponse{
+		FollowersCount: counts.FollowersCount,
+		FollowingCount: counts.FollowingCount,
+	}, nil
+}
+
+func (s *server) IsFollowing(ctx context.Context, req *pb.IsFollowingRequest) (*pb.IsFollowingResponse, error) {
+	followerID, err := uuid.Parse(req.GetFollowerId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse follower id from incoming request: IsFollowing", err)
+	}
+
+	followeeID, err := uuid.Parse(req.GetFolloweeId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse followee id from incoming request: IsFollowing", err)
+	}
+
+	getFollowParams := database.GetFollowParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	}
+
+	follow, err := s.db.GetFollow(ctx, getFollowParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &pb.IsFollowingResponse{
+			IsFollowing: false,
+		}, nil
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get follow from db: IsFollowing", err)
+	}
+
+	return &pb.IsFollowingResponse{
+		IsFollowing: true,
+		FollowedAt:  timestamppb.New(follow.CreatedAt),
+	}, nil
+}
+
+func (s *server) GetMutualFollowers(
+	ctx context.Context,
+	req *pb.GetMutualFollowersRequest,
+) (*pb.GetMutualFollowersResponse, error) {
+	userA, err := uuid.Parse(req.GetUserA())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user_a from incoming request: GetMutualFollowers", err)
+	}
+
+	userB, err := uuid.Parse(req.GetUserB())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user_b from incoming request: GetMutualFollowers", err)
+	}
+
+	for _, userID := range []uuid.UUID{userA, userB} {
+		if err := s.requireFollowGraphAccess(ctx, userID, "GetMutualFollowers"); err != nil {
+			return nil, err
+		}
+	}
+
+	offset, err := helper.DecodeOffsetToken(req.GetPageToken())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "invalid page token: GetMutualFollowers", err)
+	}
+	pageSize := helper.PageSize(req.GetPageSize())
+
+	listMutualFollowersParams := database.ListMutualFollowersParams{
+		UserA:      userA,
+		UserB:      userB,
+		PageSize:   pageSize + 1,
+		PageOffset: offset,
+	}
+
+	users, err := s.db.ListMutualFollowers(ctx, listMutualFollowersParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get mutual followers from db: GetMutualFollowers", err)
+	}
+
+	nextPageToken := ""
+	if len(users) > int(pageSize) {
+		users = users[:pageSize]
+		nextPageToken = helper.EncodeOffsetToken(offset + pageSize)
+	}
+
+	pbUsers := make([]*pb.PublicUser, len(users))
+	for i, user := range users {
+		pbUsers[i] = newPbPublicUser(user)
+	}
+
+	return &pb.GetMutualFollowersResponse{
+		Users:         pbUsers,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+func (s *server) SuggestUsers(ctx context.Context, req *pb.SuggestUsersRequest) (*pb.SuggestUsersResponse, error) {
+	userID, err := requireUserID(ctx, "SuggestUsers")
+	if err != nil {
+		return nil, err
+	}
+
+	suggestUsersParams := database.SuggestUsersParams{
+		UserID:     userID,
+		MaxResults: helper.PageSize(req.GetLimit()),
+	}
+
+	rows, err := s.db.SuggestUsers(ctx, suggestUsersParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get suggestions from db: SuggestUsers", err)
+	}
+
+	suggestions := make([]*pb.UserSuggestion, len(rows))
+	for i, row := range rows {
+		suggestions[i] = &pb.UserSuggestion{
+			User:               newPbPublicUser(row.User),
+			MutualFollowsCount: row.MutualFollowsCount,
+			FollowsYou:         row.FollowsYou,
+		}
+	}
+
+	return &pb.SuggestUsersResponse{
+		Suggestions: suggestions,
+	}, nil
+}
+
+// requireFollowGraphAccess refuses to show who follows or is followed by a private
+// account to anyone but its owner, its approved followers and admins.
+func (s *server) requireFollowGraphAccess(ctx context.Context, userID uuid.UUID, method string) error {
+	user, err := s.db.GetUserById(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return helper.RespondWithErrorGRPC(ctx, codes.NotFound, "user not found: "+method, err)
+	}
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: "+method, err)
+	}
+
+	c := callerFromContext(ctx)
+	if !user.IsPrivate || c.viewerOf(userID) != viewerOther {
+		return nil
+	}
+
+	if c.authenticated {
+		getFollowParams := database.GetFollowParams{
+			FollowerID: c.id,
+			FolloweeID: userID,
+		}
+		_, err := s.db.GetFollow(ctx, getFollowParams)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get follow from db: "+method, err)
+		}
+	}
+
+	return helper.RespondWithErrorGRPC(ctx, codes.PermissionDenied, "account is private: "+method, nil)
+}
+
+// newPbFollow builds a Follow entry exposing only the public fields of the user.
+func newPbFollow(user database.User, followedAt time.Time) *pb.Follow {
+	return &pb.Follow{
+		User:       newPbPublicUser(user),
+		FollowedAt: timestamppb.New(followedAt),
+	}
+}
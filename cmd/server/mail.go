@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/imhasandl/user-service/internal/mail"
+	"google.golang.org/grpc/metadata"
+)
+
+// codeMail is the data of the emails the service sends. Each template uses the
+// fields it needs.
+type codeMail struct {
+	Code     string
+	NewEmail string
+	Link     string
+}
+
+// sendMail renders the template called name in the caller's language and emails it
+// to to. The language comes from the accept-language metadata of the request.
+func (s *server) sendMail(ctx context.Context, to, name string, data codeMail) error {
+	locale := mail.DefaultLocale
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("accept-language"); len(values) > 0 {
+			locale = mail.LocaleFromAcceptLanguage(values[0])
+		}
+	}
+
+	msg, err := s.templates.Render(locale, name, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+
+	return s.mailer.Send(ctx, msg)
+}
+
+// formatCode writes a verification code the way users type it back, with leading zeros.
+func formatCode(code int32) string {
+	return fmt.Sprintf("%06d", code)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/imhasandl/user-service/internal/mail"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestSendMail(t *testing.T) {
+	templates, err := mail.DefaultTemplates()
+	if err != nil {
+		t.Fatalf("DefaultTemplates: %v", err)
+	}
+	mailer := mail.NewMemoryMailer()
+	s := &server{mailer: mailer, templates: templates}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("accept-language", "ru-RU,ru;q=0.9"))
+	err = s.sendMail(ctx, "user@example.com", mail.TemplatePasswordReset, codeMail{Code: formatCode(42), Link: "https://example.com/reset?token=abc"})
+	if err != nil {
+		t.Fatalf("sendMail: %v", err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.To != "user@example.com" {
+		t.Errorf("to = %q, want user@example.com", msg.To)
+	}
+	if !strings.Contains(msg.Text, "000042") {
+		t.Errorf("text = %q, want the code with leading zeros", msg.Text)
+	}
+	if !strings.Contains(msg.Text, "https://example.com/reset?token=abc") {
+		t.Errorf("text = %q, want the reset link", msg.Text)
+	}
+	if msg.Subject != "Сброс пароля" {
+		t.Errorf("subject = %q, want the russian template", msg.Subject)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// viewer is who a user is shown to, which decides the fields that leave the service.
+// Password hashes and verification codes are never sent to anyone.
+type viewer int
+
+const (
+	// viewerOther is any other user, or an anonymous caller: public fields only.
+	viewerOther viewer = iota
+	// viewerSelf is the user themselves: the public fields plus email, birthday, role
+	// and verification time.
+	viewerSelf
+	// viewerAdmin is an administrator, who sees what the user sees.
+	viewerAdmin
+)
+
+// caller is whoever sent an incoming request.
+type caller struct {
+	id            uuid.UUID
+	authenticated bool
+	admin         bool
+}
+
+// viewerOf tells how c sees the user with the given id.
+func (c caller) viewerOf(userID uuid.UUID) viewer {
+	switch {
+	case c.admin:
+		return viewerAdmin
+	case c.authenticated && c.id == userID:
+		return viewerSelf
+	default:
+		return viewerOther
+	}
+}
+
+// newPbUser copies the fields of user that v may see.
+func newPbUser(user database.User, v viewer) *pb.User {
+	pbUser := &pb.User{
+		Id:         user.ID.String(),
+		CreatedAt:  timestamppb.New(user.CreatedAt),
+		UpdatedAt:  timestamppb.New(user.UpdatedAt),
+		Username:   user.Username,
+		IsPremium:  user.IsPremium,
+		IsVerified: user.IsVerified,
+		IsPrivate:  user.IsPrivate,
+		Profile:    newPbProfile(user, v != viewerOther),
+	}
+	if v != viewerOther {
+		pbUser.Email = user.Email
+		pbUser.Role = pbRole(user.Role)
+		if user.VerifiedAt.Valid {
+			pbUser.VerifiedAt = timestamppb.New(user.VerifiedAt.Time)
+		}
+	}
+	return pbUser
+}
+
+// newPbPublicUser copies the fields of user that anyone may see about another account.
+func newPbPublicUser(user database.User) *pb.PublicUser {
+	return &pb.PublicUser{
+		Id:         user.ID.String(),
+		CreatedAt:  timestamppb.New(user.CreatedAt),
+		UpdatedAt:  timestamppb.New(user.UpdatedAt),
+		Username:   user.Username,
+		IsPremium:  user.IsPremium,
+		IsVerified: user.IsVerified,
+		IsPrivate:  user.IsPrivate,
+		Profile:    newPbProfile(user, false),
+	}
+}
+
+// newPbProfile copies the profile columns of user into its protobuf form. The
+// birthday is left out unless withBirthday is set.
+func newPbProfile(user database.User, withBirthday bool) *pb.Profile {
+	profile := &pb.Profile{
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+		Links:       user.Links,
+		Location:    user.Location,
+	}
+	if withBirthday && user.Birthday.Valid {
+		profile.Birthday = user.Birthday.Time.Format(time.DateOnly)
+	}
+	return profile
+}
@@ -0,0 +1,449 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/webauthn"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// Ceremonies, as stored in webauthn_challenges.ceremony.
+const (
+	ceremonyRegistration = "registration"
+	ceremonyAssertion    = "assertion"
+)
+
+const (
+	// defaultPasskeyName names passkeys registered without a name.
+	defaultPasskeyName = "Passkey"
+	// maxPasskeyNameLength is in characters.
+	maxPasskeyNameLength = 64
+)
+
+// passkeyTransports are the transports of WebAuthn Level 3; others sent by clients are
+// dropped.
+var passkeyTransports = []string{"ble", "hybrid", "internal", "nfc", "smart-card", "usb"}
+
+// maxPasskeyAssertionsPerIP limits BeginPasskeyAssertion per rateLimitWindow. It
+// needs no account, so without a limit anyone could fill the challenge table.
+const maxPasskeyAssertionsPerIP = 100
+
+// invalidPasskeyAssertionMessage is returned for every assertion that fails, so that
+// callers can't tell which credentials exist.
+const invalidPasskeyAssertionMessage = "passkey can't be verified: FinishPasskeyAssertion"
+
+// BeginPasskeyRegistration starts adding a passkey to the caller's account and returns
+// the options for their authenticator. Passkeys the caller already has are excluded,
+// so an authenticator isn't registered twice.
+func (s *server) BeginPasskeyRegistration(ctx context.Context, req *pb.BeginPasskeyRegistrationRequest) (*pb.BeginPasskeyRegistrationResponse, error) {
+	userID, err := requireUserID(ctx, "BeginPasskeyRegistration")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireRelyingParty(ctx, "BeginPasskeyRegistration"); err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: BeginPasskeyRegistration", err)
+	}
+
+	if err := s.requireSecondFactor(ctx, userID, req.GetSecondFactor(), "BeginPasskeyRegistration"); err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.db.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't list passkeys from db: BeginPasskeyRegistration", err)
+	}
+
+	challengeID, challenge, err := s.newPasskeyChallenge(ctx, uuid.NullUUID{UUID: userID, Valid: true}, ceremonyRegistration)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't create challenge: BeginPasskeyRegistration", err)
+	}
+
+	displayName := user.DisplayName
+	if displayName == "" {
+		displayName = user.Username
+	}
+	webauthnUser := webauthn.User{
+		ID:          userID[:],
+		Name:        user.Username,
+		DisplayName: displayName,
+	}
+	options, err := s.relyingParty.CreationOptions(webauthnUser, challenge, credentialDescriptors(credentials))
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't encode options: BeginPasskeyRegistration", err)
+	}
+
+	return &pb.BeginPasskeyRegistrationResponse{
+		ChallengeId: challengeID.String(),
+		OptionsJson: string(options),
+	}, nil
+}
+
+// FinishPasskeyRegistration checks the authenticator's response to the options of
+// BeginPasskeyRegistration and stores the new passkey.
+func (s *server) FinishPasskeyRegistration(ctx context.Context, req *pb.FinishPasskeyRegistrationRequest) (*pb.FinishPasskeyRegistrationResponse, error) {
+	userID, err := requireUserID(ctx, "FinishPasskeyRegistration")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireRelyingParty(ctx, "FinishPasskeyRegistration"); err != nil {
+		return nil, err
+	}
+
+	name, err := parsePasskeyName(ctx, req.GetName(), "FinishPasskeyRegistration")
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.takePasskeyChallenge(ctx, req.GetChallengeId(), ceremonyRegistration, "FinishPasskeyRegistration")
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID.UUID != userID {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "challenge not found: FinishPasskeyRegistration", nil)
+	}
+
+	credential, err := s.relyingParty.VerifyRegistration(challenge.Challenge, req.GetClientDataJson(), req.GetAttestationObject())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, err.Error()+": FinishPasskeyRegistration", nil)
+	}
+
+	createWebAuthnCredentialParams := database.CreateWebAuthnCredentialParams{
+		ID:             uuid.New(),
+		UserID:         userID,
+		CredentialID:   credential.ID,
+		Name:           name,
+		PublicKey:      credential.PublicKey,
+		Algorithm:      int32(credential.Algorithm),
+		SignCount:      int64(credential.SignCount),
+		Aaguid:         credential.AAGUID,
+		Transports:     parsePasskeyTransports(req.GetTransports()),
+		BackupEligible: credential.BackupEligible,
+		BackedUp:       credential.BackedUp,
+		CreatedAt:      time.Now().UTC(),
+	}
+	stored, err := s.db.CreateWebAuthnCredential(ctx, createWebAuthnCredentialParams)
+	if helper.IsUniqueViolation(err) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.AlreadyExists, "passkey is already registered: FinishPasskeyRegistration", err)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't store passkey in db: FinishPasskeyRegistration", err)
+	}
+
+	return &pb.FinishPasskeyRegistrationResponse{
+		Passkey: newPbPasskey(stored),
+	}, nil
+}
+
+// BeginPasskeyAssertion starts a passwordless login and returns the options for the
+// authenticator, which offers whichever passkeys it holds for this service.
+func (s *server) BeginPasskeyAssertion(ctx context.Context, req *pb.BeginPasskeyAssertionRequest) (*pb.BeginPasskeyAssertionResponse, error) {
+	if err := s.requireRelyingParty(ctx, "BeginPasskeyAssertion"); err != nil {
+		return nil, err
+	}
+
+	err := s.limitAttempts(ctx, "passkey-assertion", "", 0, maxPasskeyAssertionsPerIP, "BeginPasskeyAssertion")
+	if err != nil {
+		return nil, err
+	}
+
+	challengeID, challenge, err := s.newPasskeyChallenge(ctx, uuid.NullUUID{}, ceremonyAssertion)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't create challenge: BeginPasskeyAssertion", err)
+	}
+
+	options, err := s.relyingParty.RequestOptions(challenge)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't encode options: BeginPasskeyAssertion", err)
+	}
+
+	return &pb.BeginPasskeyAssertionResponse{
+		ChallengeId: challengeID.String(),
+		OptionsJson: string(options),
+	}, nil
+}
+
+// FinishPasskeyAssertion checks the authenticator's response to the options of
+// BeginPasskeyAssertion and tells which user signed in. The service doesn't issue
+// tokens itself: the auth service calls it and signs the user in on success.
+func (s *server) FinishPasskeyAssertion(ctx context.Context, req *pb.FinishPasskeyAssertionRequest) (*pb.FinishPasskeyAssertionResponse, error) {
+	if err := s.requireRelyingParty(ctx, "FinishPasskeyAssertion"); err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.takePasskeyChallenge(ctx, req.GetChallengeId(), ceremonyAssertion, "FinishPasskeyAssertion")
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.passkeyOfAssertion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, err := s.relyingParty.VerifyAssertion(
+		challenge.Challenge,
+		credential.PublicKey,
+		req.GetClientDataJson(),
+		req.GetAuthenticatorData(),
+		req.GetSignature(),
+	)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, invalidPasskeyAssertionMessage, err)
+	}
+
+	used, err := s.usePasskey(ctx, credential, assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.FinishPasskeyAssertionResponse{
+		UserId:  used.UserID.String(),
+		Passkey: newPbPasskey(used),
+	}, nil
+}
+
+// ListPasskeys returns the passkeys of the caller, oldest first.
+func (s *server) ListPasskeys(ctx context.Context, req *pb.ListPasskeysRequest) (*pb.ListPasskeysResponse, error) {
+	userID, err := requireUserID(ctx, "ListPasskeys")
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.db.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't list passkeys from db: ListPasskeys", err)
+	}
+
+	passkeys := make([]*pb.Passkey, 0, len(credentials))
+	for _, credential := range credentials {
+		passkeys = append(passkeys, newPbPasskey(credential))
+	}
+
+	return &pb.ListPasskeysResponse{
+		Passkeys: passkeys,
+	}, nil
+}
+
+// RenamePasskey changes the name the caller gave one of their passkeys.
+func (s *server) RenamePasskey(ctx context.Context, req *pb.RenamePasskeyRequest) (*pb.RenamePasskeyResponse, error) {
+	userID, err := requireUserID(ctx, "RenamePasskey")
+	if err != nil {
+		return nil, err
+	}
+
+	passkeyID, err := uuid.Parse(req.GetPasskeyId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse passkey id from incoming request: RenamePasskey", err)
+	}
+
+	if strings.TrimSpace(req.GetName()) == "" {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "name is required: RenamePasskey", nil)
+	}
+	name, err := parsePasskeyName(ctx, req.GetName(), "RenamePasskey")
+	if err != nil {
+		return nil, err
+	}
+
+	renameWebAuthnCredentialParams := database.RenameWebAuthnCredentialParams{
+		ID:     passkeyID,
+		UserID: userID,
+		Name:   name,
+	}
+	renamed, err := s.db.RenameWebAuthnCredential(ctx, renameWebAuthnCredentialParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "passkey not found: RenamePasskey", err)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't rename passkey in db: RenamePasskey", err)
+	}
+
+	return &pb.RenamePasskeyResponse{
+		Passkey: newPbPasskey(renamed),
+	}, nil
+}
+
+// requireRelyingParty refuses passkey calls when WEBAUTHN_RP_ID isn't set.
+func (s *server) requireRelyingParty(ctx context.Context, method string) error {
+	if s.relyingParty == nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "passkeys are not set up: "+method, nil)
+	}
+	return nil
+}
+
+// newPasskeyChallenge stores a challenge for a ceremony, pruning expired ones first.
+func (s *server) newPasskeyChallenge(ctx context.Context, userID uuid.NullUUID, ceremony string) (uuid.UUID, []byte, error) {
+	now := time.Now().UTC()
+	if err := s.db.DeleteExpiredWebAuthnChallenges(ctx, now); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	createWebAuthnChallengeParams := database.CreateWebAuthnChallengeParams{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Challenge: challenge,
+		ExpiresAt: now.Add(webauthn.ChallengeTTL),
+		CreatedAt: now,
+	}
+	if err := s.db.CreateWebAuthnChallenge(ctx, createWebAuthnChallengeParams); err != nil {
+		return uuid.Nil, nil, err
+	}
+	return createWebAuthnChallengeParams.ID, challenge, nil
+}
+
+// takePasskeyChallenge uses up the live challenge with the given id.
+func (s *server) takePasskeyChallenge(ctx context.Context, rawID, ceremony, method string) (database.WebauthnChallenge, error) {
+	challengeID, err := uuid.Parse(rawID)
+	if err != nil {
+		return database.WebauthnChallenge{}, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse challenge id: "+method, err)
+	}
+
+	takeWebAuthnChallengeParams := database.TakeWebAuthnChallengeParams{
+		ID:       challengeID,
+		Ceremony: ceremony,
+	}
+	challenge, err := s.db.TakeWebAuthnChallenge(ctx, takeWebAuthnChallengeParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.WebauthnChallenge{}, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "challenge not found: "+method, err)
+	}
+	if err != nil {
+		return database.WebauthnChallenge{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get challenge from db: "+method, err)
+	}
+	if !time.Now().UTC().Before(challenge.ExpiresAt) {
+		return database.WebauthnChallenge{}, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "challenge expired: "+method, nil)
+	}
+
+	return challenge, nil
+}
+
+// passkeyOfAssertion finds the passkey an assertion claims to be made with. It must
+// belong to an account that isn't deleted, and match the user handle if one was sent.
+func (s *server) passkeyOfAssertion(ctx context.Context, req *pb.FinishPasskeyAssertionRequest) (database.WebauthnCredential, error) {
+	credential, err := s.db.GetWebAuthnCredentialByCredentialID(ctx, req.GetCredentialId())
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.WebauthnCredential{}, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, invalidPasskeyAssertionMessage, err)
+	}
+	if err != nil {
+		return database.WebauthnCredential{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get passkey from db: FinishPasskeyAssertion", err)
+	}
+
+	if len(req.GetUserHandle()) > 0 && !bytes.Equal(req.GetUserHandle(), credential.UserID[:]) {
+		return database.WebauthnCredential{}, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, invalidPasskeyAssertionMessage, nil)
+	}
+
+	_, err = s.db.GetUserById(ctx, credential.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.WebauthnCredential{}, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, invalidPasskeyAssertionMessage, err)
+	}
+	if err != nil {
+		return database.WebauthnCredential{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: FinishPasskeyAssertion", err)
+	}
+
+	return credential, nil
+}
+
+// usePasskey records a verified assertion. A sign count that rolled back is refused.
+func (s *server) usePasskey(ctx context.Context, credential database.WebauthnCredential, assertion webauthn.Assertion) (database.WebauthnCredential, error) {
+	signCount := int64(assertion.SignCount)
+	if signCountRolledBack(credential.SignCount, signCount) {
+		return database.WebauthnCredential{}, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, invalidPasskeyAssertionMessage, nil)
+	}
+
+	useWebAuthnCredentialParams := database.UseWebAuthnCredentialParams{
+		SignCount:         signCount,
+		BackedUp:          assertion.BackedUp,
+		LastUsedAt:        sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		ID:                credential.ID,
+		PreviousSignCount: credential.SignCount,
+	}
+	used, err := s.db.UseWebAuthnCredential(ctx, useWebAuthnCredentialParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.WebauthnCredential{}, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, invalidPasskeyAssertionMessage, err)
+	}
+	if err != nil {
+		return database.WebauthnCredential{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't update passkey in db: FinishPasskeyAssertion", err)
+	}
+
+	return used, nil
+}
+
+// signCountRolledBack reports whether the sign count of an assertion didn't grow past
+// the stored one. For an authenticator that keeps a count, that means the passkey was
+// cloned. Authenticators that don't keep one, such as synced passkeys, always send 0.
+func signCountRolledBack(stored, signCount int64) bool {
+	return (signCount != 0 || stored != 0) && signCount <= stored
+}
+
+// parsePasskeyName trims a passkey name sent by a client and checks its length.
+func parsePasskeyName(ctx context.Context, raw, method string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if name == "" {
+		return defaultPasskeyName, nil
+	}
+	if utf8.RuneCountInString(name) > maxPasskeyNameLength {
+		return "", helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "name is too long: "+method, nil)
+	}
+	return name, nil
+}
+
+// parsePasskeyTransports keeps the known transports among those sent by a client.
+func parsePasskeyTransports(raw []string) []string {
+	transports := []string{}
+	for _, transport := range raw {
+		if slices.Contains(passkeyTransports, transport) && !slices.Contains(transports, transport) {
+			transports = append(transports, transport)
+		}
+	}
+	return transports
+}
+
+func credentialDescriptors(credentials []database.WebauthnCredential) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, webauthn.CredentialDescriptor{
+			ID:         credential.CredentialID,
+			Transports: credential.Transports,
+		})
+	}
+	return descriptors
+}
+
+func newPbPasskey(credential database.WebauthnCredential) *pb.Passkey {
+	passkey := &pb.Passkey{
+		Id:         credential.ID.String(),
+		Name:       credential.Name,
+		Aaguid:     credential.Aaguid.String(),
+		Transports: credential.Transports,
+		BackedUp:   credential.BackedUp,
+		CreatedAt:  timestamppb.New(credential.CreatedAt),
+	}
+	if credential.LastUsedAt.Valid {
+		passkey.LastUsedAt = timestamppb.New(credential.LastUsedAt.Time)
+	}
+	return passkey
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/webauthn"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSignCountRolledBack(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    int64
+		signCount int64
+		want      bool
+	}{
+		{name: "no count kept", stored: 0, signCount: 0, want: false},
+		{name: "first count", stored: 0, signCount: 1, want: false},
+		{name: "count grew", stored: 5, signCount: 6, want: false},
+		{name: "count grew by more than one", stored: 5, signCount: 100, want: false},
+		{name: "count repeated", stored: 5, signCount: 5, want: true},
+		{name: "count went back", stored: 5, signCount: 4, want: true},
+		{name: "count dropped to zero", stored: 5, signCount: 0, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signCountRolledBack(tt.stored, tt.signCount); got != tt.want {
+				t.Errorf("signCountRolledBack(%d, %d) = %t, want %t", tt.stored, tt.signCount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsePasskeyRefusesRolledBackSignCount(t *testing.T) {
+	// The count is checked before the database is touched, so the server needs none.
+	s := &server{}
+	credential := database.WebauthnCredential{SignCount: 10}
+
+	_, err := s.usePasskey(context.Background(), credential, webauthn.Assertion{SignCount: 9})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("usePasskey error = %v, want InvalidArgument", err)
+	}
+	if msg := status.Convert(err).Message(); msg != invalidPasskeyAssertionMessage {
+		t.Errorf("message = %q, want %q", msg, invalidPasskeyAssertionMessage)
+	}
+}
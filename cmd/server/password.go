@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/password"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+)
+
+// passwordHistoryLength is how many earlier passwords are kept for the no-reuse rule,
+// on top of the current one.
+const passwordHistoryLength = 5
+
+// checkNewPassword applies the password policy to newPassword as the next password
+// of user.
+func (s *server) checkNewPassword(ctx context.Context, user database.User, newPassword, method string) error {
+	listPasswordHistoryParams := database.ListPasswordHistoryParams{
+		UserID: user.ID,
+		Limit:  passwordHistoryLength,
+	}
+	history, err := s.db.ListPasswordHistory(ctx, listPasswordHistoryParams)
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get password history: "+method, err)
+	}
+
+	candidate := password.Candidate{
+		Password:       newPassword,
+		PreviousHashes: append([]string{user.Password}, history...),
+	}
+	if err := s.passwordPolicy.Check(candidate); err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, err.Error()+": "+method, nil)
+	}
+
+	return nil
+}
+
+// replacePassword sets hashedPassword as the password of user and remembers the old
+// one for the no-reuse rule. Every refresh token of the user is deleted, so sessions
+// opened with the old password, stolen or not, end.
+func replacePassword(ctx context.Context, q *database.Queries, user database.User, hashedPassword string) error {
+	addPasswordHistoryParams := database.AddPasswordHistoryParams{
+		UserID:       user.ID,
+		PasswordHash: user.Password,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := q.AddPasswordHistory(ctx, addPasswordHistoryParams); err != nil {
+		return err
+	}
+
+	prunePasswordHistoryParams := database.PrunePasswordHistoryParams{
+		UserID: user.ID,
+		Limit:  passwordHistoryLength,
+	}
+	if err := q.PrunePasswordHistory(ctx, prunePasswordHistoryParams); err != nil {
+		return err
+	}
+
+	changePasswordParams := database.ChangePasswordParams{
+		ID:       user.ID,
+		Password: hashedPassword,
+	}
+	if err := q.ChangePassword(ctx, changePasswordParams); err != nil {
+		return err
+	}
+
+	return q.DeleteUserRefreshTokens(ctx, user.ID)
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	authService "github.com/imhasandl/auth-service/cmd/auth"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/mail"
+	"github.com/imhasandl/user-service/internal/verification"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// Rate limits of the forgot-password flow, per rateLimitWindow. Every call counts
+// whether or not the account exists, so hitting a limit doesn't tell accounts apart.
+const (
+	maxResetRequestsPerIdentifier      = 3
+	maxResetRequestsPerIP              = 20
+	maxResetConfirmationsPerIdentifier = 10
+	maxResetConfirmationsPerIP         = 50
+)
+
+const (
+	passwordResetRequestedStatus = "If the account exists, a password reset code was sent to its email"
+
+	// invalidPasswordResetMessage is returned for every confirmation that fails, so
+	// callers can't tell an unknown account from a wrong code.
+	invalidPasswordResetMessage = "invalid or expired password reset code: ConfirmPasswordReset"
+)
+
+// errInvalidPasswordReset is returned by passwordResetToken when the code or token
+// doesn't match a live reset of the user.
+var errInvalidPasswordReset = errors.New("invalid password reset")
+
+// RequestPasswordReset emails a reset code to the account with the given email or
+// username. It answers the same way whether or not the account exists.
+func (s *server) RequestPasswordReset(
+	ctx context.Context,
+	req *pb.RequestPasswordResetRequest,
+) (*pb.RequestPasswordResetResponse, error) {
+	identifier := strings.TrimSpace(req.GetIdentifier())
+	if identifier == "" {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "identifier is required: RequestPasswordReset", nil)
+	}
+
+	err := s.limitAttempts(ctx, "request", callerIdentifier(ctx, identifier), maxResetRequestsPerIdentifier, maxResetRequestsPerIP, "RequestPasswordReset")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userByIdentifier(ctx, identifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &pb.RequestPasswordResetResponse{Status: passwordResetRequestedStatus}, nil
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: RequestPasswordReset", err)
+	}
+
+	if err := s.issuePasswordReset(ctx, user); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't issue password reset: RequestPasswordReset", err)
+	}
+
+	return &pb.RequestPasswordResetResponse{Status: passwordResetRequestedStatus}, nil
+}
+
+// ConfirmPasswordReset sets a new password using the code or token of the latest
+// reset of an account. Codes and tokens work once.
+func (s *server) ConfirmPasswordReset(
+	ctx context.Context,
+	req *pb.ConfirmPasswordResetRequest,
+) (*pb.ConfirmPasswordResetResponse, error) {
+	identifier := strings.TrimSpace(req.GetIdentifier())
+	if identifier == "" || req.GetNewPassword() == "" {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "identifier and new password are required: ConfirmPasswordReset", nil)
+	}
+
+	err := s.limitAttempts(ctx, "confirm", callerIdentifier(ctx, identifier), maxResetConfirmationsPerIdentifier, maxResetConfirmationsPerIP, "ConfirmPasswordReset")
+	if err != nil {
+		return nil, err
+	}
+
+	user, resetToken, err := s.passwordResetToken(ctx, identifier, req.GetCodeOrToken())
+	if err != nil {
+		return nil, passwordResetError(ctx, err, "can't check password reset code: ConfirmPasswordReset")
+	}
+
+	if err := s.checkNewPassword(ctx, user, req.GetNewPassword(), "ConfirmPasswordReset"); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := authService.HashPassword(req.GetNewPassword())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't hash new password: ConfirmPasswordReset", err)
+	}
+
+	// The emailed code alone isn't enough to take over an account with 2FA on.
+	if err := s.requireSecondFactor(ctx, user.ID, req.GetSecondFactor(), "ConfirmPasswordReset"); err != nil {
+		return nil, err
+	}
+
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		return completePasswordReset(ctx, q, user, resetToken, hashedPassword)
+	})
+	if err != nil {
+		return nil, passwordResetError(ctx, err, "can't reset password: ConfirmPasswordReset")
+	}
+
+	return &pb.ConfirmPasswordResetResponse{
+		Status: "Password changed successfully",
+	}, nil
+}
+
+// userByIdentifier looks a user up by email or username.
+func (s *server) userByIdentifier(ctx context.Context, identifier string) (database.User, error) {
+	getUserByEmailOrUsernameParams := database.GetUserByEmailOrUsernameParams{
+		Email:    identifier,
+		Username: identifier,
+	}
+	return s.db.GetUserByEmailOrUsername(ctx, getUserByEmailOrUsernameParams)
+}
+
+// issuePasswordReset replaces any pending reset of user with a new one and emails its
+// code, along with a reset link when a reset page is configured. The email is sent in
+// the background, so the response takes as long for accounts that exist as for those
+// that don't.
+func (s *server) issuePasswordReset(ctx context.Context, user database.User) error {
+	code, err := authService.GenerateVerificationCode()
+	if err != nil {
+		return err
+	}
+
+	link, tokenHash, err := s.newPasswordResetLink(user.Email)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		invalidatePasswordResetTokensParams := database.InvalidatePasswordResetTokensParams{
+			UserID: user.ID,
+			UsedAt: sql.NullTime{Time: now, Valid: true},
+		}
+		if err := q.InvalidatePasswordResetTokens(ctx, invalidatePasswordResetTokensParams); err != nil {
+			return err
+		}
+
+		createPasswordResetTokenParams := database.CreatePasswordResetTokenParams{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			CodeHash:  s.verifier.Hash(user.ID, code),
+			TokenHash: tokenHash,
+			ExpiresAt: now.Add(verification.CodeTTL),
+			CreatedAt: now,
+		}
+		return q.CreatePasswordResetToken(ctx, createPasswordResetTokenParams)
+	})
+	if err != nil {
+		return err
+	}
+
+	data := codeMail{
+		Code: formatCode(code),
+		Link: link,
+	}
+	mailCtx := context.WithoutCancel(ctx)
+	go func() {
+		err := s.sendMail(mailCtx, user.Email, mail.TemplatePasswordReset, data)
+		if err != nil {
+			log.Printf("can't send password reset email to user %s: %v", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// newPasswordResetLink returns a link to the reset page for the account with email,
+// carrying a new token, and the hash of the token to store. Without a reset page a
+// token could never reach the user, so none is made and only the code works.
+func (s *server) newPasswordResetLink(email string) (string, sql.NullString, error) {
+	if s.passwordResetURL == "" {
+		return "", sql.NullString{}, nil
+	}
+
+	link, err := url.Parse(s.passwordResetURL)
+	if err != nil {
+		return "", sql.NullString{}, err
+	}
+
+	token, err := verification.NewToken()
+	if err != nil {
+		return "", sql.NullString{}, err
+	}
+
+	query := link.Query()
+	query.Set("identifier", email)
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), sql.NullString{String: s.verifier.HashToken(token), Valid: true}, nil
+}
+
+// passwordResetToken finds the account behind identifier and the live reset of it
+// that codeOrToken belongs to. Codes count against the attempts of the latest
+// reset; tokens are long enough not to need a limit of their own.
+func (s *server) passwordResetToken(ctx context.Context, identifier, codeOrToken string) (database.User, database.PasswordResetToken, error) {
+	user, err := s.userByIdentifier(ctx, identifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, database.PasswordResetToken{}, errInvalidPasswordReset
+	}
+	if err != nil {
+		return database.User{}, database.PasswordResetToken{}, err
+	}
+
+	var resetToken database.PasswordResetToken
+	if code, ok := parseResetCode(codeOrToken); ok {
+		resetToken, err = s.passwordResetTokenByCode(ctx, user.ID, code)
+	} else {
+		resetToken, err = s.passwordResetTokenByToken(ctx, user.ID, codeOrToken)
+	}
+	return user, resetToken, err
+}
+
+func (s *server) passwordResetTokenByToken(ctx context.Context, userID uuid.UUID, token string) (database.PasswordResetToken, error) {
+	getPasswordResetTokenByHashParams := database.GetPasswordResetTokenByHashParams{
+		TokenHash: sql.NullString{String: s.verifier.HashToken(token), Valid: true},
+		UserID:    userID,
+	}
+	resetToken, err := s.db.GetPasswordResetTokenByHash(ctx, getPasswordResetTokenByHashParams)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !time.Now().UTC().Before(resetToken.ExpiresAt)) {
+		return database.PasswordResetToken{}, errInvalidPasswordReset
+	}
+	return resetToken, err
+}
+
+func (s *server) passwordResetTokenByCode(ctx context.Context, userID uuid.UUID, code int32) (database.PasswordResetToken, error) {
+	resetToken, err := s.db.GetLatestPasswordResetToken(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.PasswordResetToken{}, errInvalidPasswordReset
+	}
+	if err != nil {
+		return database.PasswordResetToken{}, err
+	}
+
+	attempts, err := s.db.RecordPasswordResetAttempt(ctx, resetToken.ID)
+	if err != nil {
+		return database.PasswordResetToken{}, err
+	}
+
+	state := verification.State{
+		Hash:      resetToken.CodeHash,
+		ExpiresAt: resetToken.ExpiresAt,
+		Attempts:  resetToken.Attempts,
+	}
+	if err := s.verifier.Check(state, userID, code, attempts, time.Now().UTC()); err != nil {
+		return database.PasswordResetToken{}, errInvalidPasswordReset
+	}
+
+	return resetToken, nil
+}
+
+// completePasswordReset uses up resetToken and every other pending reset of user,
+// then sets the new password. A token used by a concurrent request is rejected.
+func completePasswordReset(ctx context.Context, q *database.Queries, user database.User, resetToken database.PasswordResetToken, hashedPassword string) error {
+	usedAt := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	usePasswordResetTokenParams := database.UsePasswordResetTokenParams{
+		ID:     resetToken.ID,
+		UsedAt: usedAt,
+	}
+	used, err := q.UsePasswordResetToken(ctx, usePasswordResetTokenParams)
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return errInvalidPasswordReset
+	}
+
+	invalidatePasswordResetTokensParams := database.InvalidatePasswordResetTokensParams{
+		UserID: user.ID,
+		UsedAt: usedAt,
+	}
+	if err := q.InvalidatePasswordResetTokens(ctx, invalidatePasswordResetTokensParams); err != nil {
+		return err
+	}
+
+	return replacePassword(ctx, q, user, hashedPassword)
+}
+
+// passwordResetError turns errInvalidPasswordReset into the one error callers see for
+// every failed confirmation, and anything else into an internal error.
+func passwordResetError(ctx context.Context, err error, msg string) error {
+	if errors.Is(err, errInvalidPasswordReset) {
+		return helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, invalidPasswordResetMessage, nil)
+	}
+	return helper.RespondWithErrorGRPC(ctx, codes.Internal, msg, err)
+}
+
+// parseResetCode tells a reset code, which has at most six digits, from a reset token.
+func parseResetCode(codeOrToken string) (int32, bool) {
+	if codeOrToken == "" || len(codeOrToken) > 6 {
+		return 0, false
+	}
+	code, err := strconv.ParseInt(codeOrToken, 10, 32)
+	if err != nil || code < 0 {
+		return 0, false
+	}
+	return int32(code), true
+}
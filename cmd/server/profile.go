@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/imhasandl/user-service/internal/database"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// Limits on the profile fields a user can set.
+const (
+	maxDisplayNameLength = 64
+	maxBioLength         = 300
+	maxLocationLength    = 100
+	maxURLLength         = 2048
+	maxProfileLinks      = 5
+)
+
+// earliestBirthday is the oldest birthday a profile accepts.
+var earliestBirthday = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// profileFields maps every field mask path UpdateProfile accepts to a function that
+// validates the new value and copies it into the query params.
+var profileFields = map[string]func(p *pb.Profile, params *database.UpdateProfileParams) error{
+	"display_name": func(p *pb.Profile, params *database.UpdateProfileParams) error {
+		params.SetDisplayName = true
+		params.DisplayName = strings.TrimSpace(p.GetDisplayName())
+		return checkLength("display_name", params.DisplayName, maxDisplayNameLength)
+	},
+	"bio": func(p *pb.Profile, params *database.UpdateProfileParams) error {
+		params.SetBio = true
+		params.Bio = strings.TrimSpace(p.GetBio())
+		return checkLength("bio", params.Bio, maxBioLength)
+	},
+	"avatar_url": func(p *pb.Profile, params *database.UpdateProfileParams) error {
+		params.SetAvatarUrl = true
+		params.AvatarUrl = strings.TrimSpace(p.GetAvatarUrl())
+		if params.AvatarUrl == "" {
+			return nil
+		}
+		return checkURL("avatar_url", params.AvatarUrl)
+	},
+	"links": func(p *pb.Profile, params *database.UpdateProfileParams) error {
+		params.SetLinks = true
+		params.Links = make([]string, 0, len(p.GetLinks()))
+		if len(p.GetLinks()) > maxProfileLinks {
+			return fmt.Errorf("links: at most %d links are allowed", maxProfileLinks)
+		}
+		for _, link := range p.GetLinks() {
+			link = strings.TrimSpace(link)
+			if err := checkURL("links", link); err != nil {
+				return err
+			}
+			params.Links = append(params.Links, link)
+		}
+		return nil
+	},
+	"location": func(p *pb.Profile, params *database.UpdateProfileParams) error {
+		params.SetLocation = true
+		params.Location = strings.TrimSpace(p.GetLocation())
+		return checkLength("location", params.Location, maxLocationLength)
+	},
+	"birthday": func(p *pb.Profile, params *database.UpdateProfileParams) error {
+		params.SetBirthday = true
+		if p.GetBirthday() == "" {
+			return nil
+		}
+		birthday, err := time.Parse(time.DateOnly, p.GetBirthday())
+		if err != nil {
+			return errors.New("birthday: must be a date in YYYY-MM-DD format")
+		}
+		if birthday.Before(earliestBirthday) || birthday.After(time.Now()) {
+			return errors.New("birthday: must be a date in the past")
+		}
+		params.Birthday = sql.NullTime{Time: birthday, Valid: true}
+		return nil
+	},
+}
+
+// UpdateProfile overwrites the profile fields listed in the update mask, or the whole
+// profile when the mask is empty.
+func (s *server) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (*pb.UpdateProfileResponse, error) {
+	userID, err := requireUserID(ctx, "UpdateProfile")
+	if err != nil {
+		return nil, err
+	}
+
+	updateProfileParams, err := newUpdateProfileParams(req.GetProfile(), req.GetUpdateMask().GetPaths())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, err.Error()+": UpdateProfile", err)
+	}
+	updateProfileParams.ID = userID
+
+	user, err := s.db.UpdateProfile(ctx, updateProfileParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't update profile in db: UpdateProfile", err)
+	}
+
+	return &pb.UpdateProfileResponse{
+		User: newPbUser(user, viewerSelf),
+	}, nil
+}
+
+// newUpdateProfileParams validates the masked fields of profile. A nil profile clears
+// the masked fields.
+func newUpdateProfileParams(profile *pb.Profile, paths []string) (database.UpdateProfileParams, error) {
+	var params database.UpdateProfileParams
+
+	if len(paths) == 0 {
+		for path := range profileFields {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+	}
+
+	for _, path := range paths {
+		setField, ok := profileFields[path]
+		if !ok {
+			return params, fmt.Errorf("unknown profile field %q in update mask", path)
+		}
+		if err := setField(profile, &params); err != nil {
+			return params, err
+		}
+	}
+
+	return params, nil
+}
+
+func checkLength(field, value string, limit int) error {
+	if utf8.RuneCountInString(value) > limit {
+		return fmt.Errorf("%s: must be at most %d characters", field, limit)
+	}
+	return nil
+}
+
+func checkURL(field, value string) error {
+	if len(value) > maxURLLength {
+		return fmt.Errorf("%s: url must be at most %d characters", field, maxURLLength)
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s: %q is not an http or https url", field, value)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/imhasandl/user-service/internal/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+)
+
+// rateLimitWindow is how far back limitAttempts counts attempts.
+const rateLimitWindow = time.Hour
+
+// limitAttempts counts an action by the caller and refuses it once the identifier or
+// the caller's IP address went over its limit within rateLimitWindow. An action
+// without an identifier is only limited per IP. Attempts older than the window are
+// pruned on the way.
+func (s *server) limitAttempts(ctx context.Context, action, identifier string, perIdentifier, perIP int64, method string) error {
+	type limit struct {
+		subject string
+		max     int64
+	}
+	limits := []limit{{subject: action + ":ip:" + clientIP(ctx), max: perIP}}
+	if identifier != "" {
+		limits = append(limits, limit{subject: action + ":identifier:" + strings.ToLower(identifier), max: perIdentifier})
+	}
+
+	now := time.Now().UTC()
+
+	if err := s.db.DeleteRateLimitEventsBefore(ctx, now.Add(-rateLimitWindow)); err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't prune attempts: "+method, err)
+	}
+
+	for _, limit := range limits {
+		recordRateLimitEventParams := database.RecordRateLimitEventParams{
+			Subject:   limit.subject,
+			CreatedAt: now,
+		}
+		if err := s.db.RecordRateLimitEvent(ctx, recordRateLimitEventParams); err != nil {
+			return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't record attempt: "+method, err)
+		}
+
+		countRateLimitEventsParams := database.CountRateLimitEventsParams{
+			Subject:   limit.subject,
+			CreatedAt: now.Add(-rateLimitWindow),
+		}
+		count, err := s.db.CountRateLimitEvents(ctx, countRateLimitEventsParams)
+		if err != nil {
+			return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't count attempts: "+method, err)
+		}
+		if count > limit.max {
+			return helper.RespondWithErrorGRPC(ctx, codes.ResourceExhausted, "too many attempts, try again later: "+method, nil)
+		}
+	}
+
+	return nil
+}
+
+// callerIdentifier scopes the identifier of an account to the caller's IP address.
+// RPCs anyone can call limit attempts on it, so a stranger who runs out an account's
+// limit doesn't lock its owner out as well.
+func callerIdentifier(ctx context.Context, identifier string) string {
+	return identifier + ":ip:" + clientIP(ctx)
+}
+
+// clientIP returns the address the request came from. Behind a proxy this is the
+// proxy's address, so every client shares its limits and the proxy should do its own
+// per-client limiting.
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/auth"
+	"github.com/imhasandl/user-service/internal/database"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// NewRoleResolver looks up the role of the caller of each request for the auth
+// interceptor.
+func NewRoleResolver(db *database.Queries) auth.RoleResolver {
+	return func(ctx context.Context, userID uuid.UUID) (auth.Role, error) {
+		role, err := db.GetUserRole(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", auth.ErrUnknownUser
+		}
+		if err != nil {
+			return "", err
+		}
+		return auth.ParseRole(role)
+	}
+}
+
+// BootstrapAdmins makes the users with the given ids administrators, so a new
+// deployment has someone to call GrantRole. main runs it at startup with the ids in
+// ADMIN_USER_IDS. Unknown ids are logged and skipped, so the service still starts
+// before the user has signed up.
+func BootstrapAdmins(ctx context.Context, db *database.Queries, userIDs []uuid.UUID) error {
+	for _, userID := range userIDs {
+		granted, err := db.GrantAdminRole(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if granted == 1 {
+			log.Printf("Made %s an admin from ADMIN_USER_IDS", userID)
+			continue
+		}
+
+		_, err = db.GetUserRole(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Printf("ADMIN_USER_IDS: no user %s", userID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GrantRole makes a user a moderator or an administrator.
+func (s *server) GrantRole(ctx context.Context, req *pb.GrantRoleRequest) (*pb.GrantRoleResponse, error) {
+	userID, err := roleTarget(ctx, req.GetUserId(), "GrantRole")
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetRole() == pb.UserRole_USER {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "every user has the USER role, use RevokeRole to take a role away: GrantRole", nil)
+	}
+
+	setUserRoleParams := database.SetUserRoleParams{
+		Role: string(roleFromPb(req.GetRole())),
+		ID:   userID,
+	}
+
+	user, err := s.db.SetUserRole(ctx, setUserRoleParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "user not found: GrantRole", err)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't set role in db: GrantRole", err)
+	}
+
+	return &pb.GrantRoleResponse{
+		User: newPbUser(user, viewerAdmin),
+	}, nil
+}
+
+// RevokeRole takes a role away from a user, who goes back to being a regular user.
+func (s *server) RevokeRole(ctx context.Context, req *pb.RevokeRoleRequest) (*pb.RevokeRoleResponse, error) {
+	userID, err := roleTarget(ctx, req.GetUserId(), "RevokeRole")
+	if err != nil {
+		return nil, err
+	}
+
+	revokeUserRoleParams := database.RevokeUserRoleParams{
+		ID:   userID,
+		Role: string(roleFromPb(req.GetRole())),
+	}
+
+	user, err := s.db.RevokeUserRole(ctx, revokeUserRoleParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "user not found or doesn't have this role: RevokeRole", err)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't revoke role in db: RevokeRole", err)
+	}
+
+	return &pb.RevokeRoleResponse{
+		User: newPbUser(user, viewerAdmin),
+	}, nil
+}
+
+// roleTarget parses the user whose role method changes. Admins can't change their own
+// role, so the last admin can't lock everyone out by accident.
+func roleTarget(ctx context.Context, rawUserID, method string) (uuid.UUID, error) {
+	callerID, err := requireUserID(ctx, method)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil {
+		return uuid.Nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user id from incoming request: "+method, err)
+	}
+
+	if userID == callerID {
+		return uuid.Nil, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "you can't change your own role: "+method, nil)
+	}
+
+	return userID, nil
+}
+
+func roleFromPb(role pb.UserRole) auth.Role {
+	switch role {
+	case pb.UserRole_MODERATOR:
+		return auth.RoleModerator
+	case pb.UserRole_ADMIN:
+		return auth.RoleAdmin
+	default:
+		return auth.RoleUser
+	}
+}
+
+func pbRole(role string) pb.UserRole {
+	switch auth.Role(role) {
+	case auth.RoleModerator:
+		return pb.UserRole_MODERATOR
+	case auth.RoleAdmin:
+		return pb.UserRole_ADMIN
+	default:
+		return pb.UserRole_USER
+	}
+}
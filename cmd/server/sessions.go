@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// ListSessions returns the sessions of the caller that haven't expired, newest first.
+// If the request carries the caller's refresh token, its session is marked as current.
+func (s *server) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	userID, err := requireUserID(ctx, "ListSessions")
+	if err != nil {
+		return nil, err
+	}
+
+	var currentID uuid.UUID
+	if req.GetRefreshToken() != "" {
+		current, err := s.sessionByRefreshToken(ctx, userID, req.GetRefreshToken())
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get session from db: ListSessions", err)
+		}
+		currentID = current.ID
+	}
+
+	listSessionsParams := database.ListSessionsParams{
+		UserID: userID,
+		Now:    time.Now().UTC(),
+	}
+	sessions, err := s.db.ListSessions(ctx, listSessionsParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't list sessions from db: ListSessions", err)
+	}
+
+	pbSessions := make([]*pb.Session, 0, len(sessions))
+	for _, session := range sessions {
+		pbSessions = append(pbSessions, newPbSession(session, session.ID == currentID))
+	}
+
+	return &pb.ListSessionsResponse{
+		Sessions: pbSessions,
+	}, nil
+}
+
+// RevokeSession signs one session of the caller out by deleting its refresh token.
+// Access tokens already issued to it stay valid until they expire.
+func (s *server) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*pb.RevokeSessionResponse, error) {
+	userID, err := requireUserID(ctx, "RevokeSession")
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := uuid.Parse(req.GetSessionId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse session id from incoming request: RevokeSession", err)
+	}
+
+	revokeSessionParams := database.RevokeSessionParams{
+		ID:     sessionID,
+		UserID: userID,
+	}
+	revoked, err := s.db.RevokeSession(ctx, revokeSessionParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't revoke session in db: RevokeSession", err)
+	}
+	if revoked == 0 {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "session not found: RevokeSession", nil)
+	}
+
+	return &pb.RevokeSessionResponse{
+		Status: true,
+	}, nil
+}
+
+// RevokeAllOtherSessions signs the caller out everywhere except in the session of the
+// refresh token sent with the request.
+func (s *server) RevokeAllOtherSessions(ctx context.Context, req *pb.RevokeAllOtherSessionsRequest) (*pb.RevokeAllOtherSessionsResponse, error) {
+	userID, err := requireUserID(ctx, "RevokeAllOtherSessions")
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetRefreshToken() == "" {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "refresh token of the session to keep is required: RevokeAllOtherSessions", nil)
+	}
+
+	current, err := s.sessionByRefreshToken(ctx, userID, req.GetRefreshToken())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "refresh token doesn't belong to a session of the caller: RevokeAllOtherSessions", err)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get session from db: RevokeAllOtherSessions", err)
+	}
+
+	revokeOtherSessionsParams := database.RevokeOtherSessionsParams{
+		UserID: userID,
+		KeepID: current.ID,
+	}
+	revoked, err := s.db.RevokeOtherSessions(ctx, revokeOtherSessionsParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't revoke sessions in db: RevokeAllOtherSessions", err)
+	}
+
+	return &pb.RevokeAllOtherSessionsResponse{
+		RevokedCount: revoked,
+	}, nil
+}
+
+// sessionByRefreshToken finds the session of userID that refreshToken belongs to.
+func (s *server) sessionByRefreshToken(ctx context.Context, userID uuid.UUID, refreshToken string) (database.RefreshToken, error) {
+	getSessionByTokenHashParams := database.GetSessionByTokenHashParams{
+		TokenHash: hashRefreshToken(refreshToken),
+		UserID:    userID,
+	}
+	return s.db.GetSessionByTokenHash(ctx, getSessionByTokenHashParams)
+}
+
+// hashRefreshToken returns the hex SHA-256 of a refresh token, as the
+// hash_refresh_token trigger stores it in refresh_tokens.token_hash.
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func newPbSession(session database.RefreshToken, current bool) *pb.Session {
+	pbSession := &pb.Session{
+		Id:        session.ID.String(),
+		CreatedAt: timestamppb.New(session.CreatedAt),
+		ExpiresAt: timestamppb.New(session.ExpiryTime),
+		Current:   current,
+		UserAgent: session.UserAgent,
+		Ip:        session.Ip,
+	}
+	if session.LastUsedAt.Valid {
+		pbSession.LastUsedAt = timestamppb.New(session.LastUsedAt.Time)
+	}
+	return pbSession
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	authService "github.com/imhasandl/auth-service/cmd/auth"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/totp"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+	pb "github.com/imhasandl/user-service/protos"
+)
+
+// Rate limits of second factor checks, per rateLimitWindow. Every check counts,
+// right or wrong, so a six-digit code can't be guessed.
+const (
+	maxSecondFactorAttemptsPerUser = 10
+	maxSecondFactorAttemptsPerIP   = 50
+)
+
+// EnrollTOTP starts turning on two-factor authentication for the caller. It returns a
+// new secret for their authenticator app; 2FA is only on once ConfirmTOTP gets a code
+// made from it. Enrolling again before that replaces the secret.
+func (s *server) EnrollTOTP(ctx context.Context, req *pb.EnrollTOTPRequest) (*pb.EnrollTOTPResponse, error) {
+	userID, err := requireUserID(ctx, "EnrollTOTP")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireTOTPCipher(ctx, "EnrollTOTP"); err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: EnrollTOTP", err)
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't generate totp secret: EnrollTOTP", err)
+	}
+
+	sealed, err := s.totpCipher.Seal(userID, secret)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't encrypt totp secret: EnrollTOTP", err)
+	}
+
+	upsertTOTPSecretParams := database.UpsertTOTPSecretParams{
+		UserID:    userID,
+		Secret:    sealed,
+		CreatedAt: time.Now().UTC(),
+	}
+	stored, err := s.db.UpsertTOTPSecret(ctx, upsertTOTPSecretParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't store totp secret in db: EnrollTOTP", err)
+	}
+	if stored == 0 {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "two-factor authentication is already on: EnrollTOTP", nil)
+	}
+
+	return &pb.EnrollTOTPResponse{
+		Secret:     totp.EncodeSecret(secret),
+		OtpauthUri: totp.URI(s.totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP turns two-factor authentication on once the caller proves their app
+// makes the right codes, and returns their recovery codes.
+func (s *server) ConfirmTOTP(ctx context.Context, req *pb.ConfirmTOTPRequest) (*pb.ConfirmTOTPResponse, error) {
+	userID, err := requireUserID(ctx, "ConfirmTOTP")
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.db.GetTOTPSecret(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "call EnrollTOTP first: ConfirmTOTP", err)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get totp secret from db: ConfirmTOTP", err)
+	}
+	if stored.ConfirmedAt.Valid {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "two-factor authentication is already on: ConfirmTOTP", nil)
+	}
+
+	step, err := s.checkTOTPCode(ctx, stored, req.GetCode(), "ConfirmTOTP")
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := totp.NewRecoveryCodes()
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't generate recovery codes: ConfirmTOTP", err)
+	}
+
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		return s.confirmTOTP(ctx, q, userID, step, recoveryCodes)
+	})
+	if errors.Is(err, errTOTPAlreadyConfirmed) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "two-factor authentication is already on: ConfirmTOTP", nil)
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't turn on two-factor authentication: ConfirmTOTP", err)
+	}
+
+	return &pb.ConfirmTOTPResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// DisableTOTP turns two-factor authentication off. It needs the caller's password as
+// well as a second factor, so a stolen session alone can't do it.
+func (s *server) DisableTOTP(ctx context.Context, req *pb.DisableTOTPRequest) (*pb.DisableTOTPResponse, error) {
+	userID, err := requireUserID(ctx, "DisableTOTP")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: DisableTOTP", err)
+	}
+
+	if err := authService.CheckPassword(user.Password, req.GetPassword()); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "password is not correct: DisableTOTP", err)
+	}
+
+	stored, err := s.confirmedTOTPSecret(ctx, userID, "DisableTOTP")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSecondFactor(ctx, stored, req.GetSecondFactor(), "DisableTOTP"); err != nil {
+		return nil, err
+	}
+
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+			return err
+		}
+		return q.DeleteTOTPSecret(ctx, userID)
+	})
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't turn off two-factor authentication: DisableTOTP", err)
+	}
+
+	return &pb.DisableTOTPResponse{
+		Status: true,
+	}, nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the caller, used or not,
+// with new ones.
+func (s *server) RegenerateRecoveryCodes(ctx context.Context, req *pb.RegenerateRecoveryCodesRequest) (*pb.RegenerateRecoveryCodesResponse, error) {
+	userID, err := requireUserID(ctx, "RegenerateRecoveryCodes")
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.confirmedTOTPSecret(ctx, userID, "RegenerateRecoveryCodes")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSecondFactor(ctx, stored, req.GetSecondFactor(), "RegenerateRecoveryCodes"); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := totp.NewRecoveryCodes()
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't generate recovery codes: RegenerateRecoveryCodes", err)
+	}
+
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		return s.replaceRecoveryCodes(ctx, q, userID, recoveryCodes)
+	})
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't replace recovery codes: RegenerateRecoveryCodes", err)
+	}
+
+	return &pb.RegenerateRecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// errTOTPAlreadyConfirmed is returned when a concurrent ConfirmTOTP turned 2FA on first.
+var errTOTPAlreadyConfirmed = errors.New("totp secret is already confirmed")
+
+// confirmTOTP marks the secret of userID as confirmed, with the step of the code that
+// confirmed it as used, and stores recoveryCodes.
+func (s *server) confirmTOTP(ctx context.Context, q *database.Queries, userID uuid.UUID, step int64, recoveryCodes []string) error {
+	confirmTOTPSecretParams := database.ConfirmTOTPSecretParams{
+		UserID:       userID,
+		ConfirmedAt:  sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		LastUsedStep: step,
+	}
+	confirmed, err := q.ConfirmTOTPSecret(ctx, confirmTOTPSecretParams)
+	if err != nil {
+		return err
+	}
+	if confirmed == 0 {
+		return errTOTPAlreadyConfirmed
+	}
+
+	return s.replaceRecoveryCodes(ctx, q, userID, recoveryCodes)
+}
+
+// replaceRecoveryCodes deletes the recovery codes of userID and stores the hashes of
+// recoveryCodes instead.
+func (s *server) replaceRecoveryCodes(ctx context.Context, q *database.Queries, userID uuid.UUID, recoveryCodes []string) error {
+	if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, code := range recoveryCodes {
+		createRecoveryCodeParams := database.CreateRecoveryCodeParams{
+			ID:        uuid.New(),
+			UserID:    userID,
+			CodeHash:  s.verifier.HashToken(totp.NormalizeRecoveryCode(code)),
+			CreatedAt: now,
+		}
+		if err := q.CreateRecoveryCode(ctx, createRecoveryCodeParams); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// confirmedTOTPSecret returns the secret of userID, refusing the call if two-factor
+// authentication isn't on.
+func (s *server) confirmedTOTPSecret(ctx context.Context, userID uuid.UUID, method string) (database.TotpSecret, error) {
+	stored, err := s.db.GetTOTPSecret(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !stored.ConfirmedAt.Valid) {
+		return database.TotpSecret{}, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "two-factor authentication is off: "+method, nil)
+	}
+	if err != nil {
+		return database.TotpSecret{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get totp secret from db: "+method, err)
+	}
+	return stored, nil
+}
+
+// requireSecondFactor checks secondFactor if userID turned two-factor authentication
+// on, and lets the call through otherwise. Sensitive RPCs call it right before they
+// change anything, so a code isn't used up by a call that fails afterwards.
+func (s *server) requireSecondFactor(ctx context.Context, userID uuid.UUID, secondFactor, method string) error {
+	stored, err := s.db.GetTOTPSecret(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !stored.ConfirmedAt.Valid) {
+		return nil
+	}
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get totp secret from db: "+method, err)
+	}
+
+	return s.checkSecondFactor(ctx, stored, secondFactor, method)
+}
+
+// checkSecondFactor accepts either a TOTP code not used before or an unused recovery
+// code, using it up.
+func (s *server) checkSecondFactor(ctx context.Context, stored database.TotpSecret, secondFactor, method string) error {
+	if secondFactor == "" {
+		return helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "second factor is required: "+method, nil)
+	}
+
+	if totp.IsCode(secondFactor) {
+		_, err := s.checkTOTPCode(ctx, stored, secondFactor, method)
+		return err
+	}
+
+	err := s.limitAttempts(ctx, "second-factor", stored.UserID.String(), maxSecondFactorAttemptsPerUser, maxSecondFactorAttemptsPerIP, method)
+	if err != nil {
+		return err
+	}
+
+	useRecoveryCodeParams := database.UseRecoveryCodeParams{
+		UserID:   stored.UserID,
+		CodeHash: s.verifier.HashToken(totp.NormalizeRecoveryCode(secondFactor)),
+		UsedAt:   sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	}
+	used, err := s.db.UseRecoveryCode(ctx, useRecoveryCodeParams)
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't use recovery code: "+method, err)
+	}
+	if used == 0 {
+		return helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "second factor is not correct: "+method, nil)
+	}
+	return nil
+}
+
+// checkTOTPCode checks code against the secret of stored and returns its time step.
+// A confirmed secret's step is used up, so the same code doesn't work twice.
+func (s *server) checkTOTPCode(ctx context.Context, stored database.TotpSecret, code, method string) (int64, error) {
+	if err := s.requireTOTPCipher(ctx, method); err != nil {
+		return 0, err
+	}
+
+	err := s.limitAttempts(ctx, "second-factor", stored.UserID.String(), maxSecondFactorAttemptsPerUser, maxSecondFactorAttemptsPerIP, method)
+	if err != nil {
+		return 0, err
+	}
+
+	secret, err := s.totpCipher.Open(stored.UserID, stored.Secret)
+	if err != nil {
+		return 0, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't decrypt totp secret: "+method, err)
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now().UTC())
+	if !ok || step <= stored.LastUsedStep {
+		return 0, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "second factor is not correct: "+method, nil)
+	}
+	if !stored.ConfirmedAt.Valid {
+		return step, nil
+	}
+
+	useTOTPStepParams := database.UseTOTPStepParams{
+		Step:   step,
+		UserID: stored.UserID,
+	}
+	used, err := s.db.UseTOTPStep(ctx, useTOTPStepParams)
+	if err != nil {
+		return 0, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't use totp code: "+method, err)
+	}
+	if used == 0 {
+		return 0, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "second factor is not correct: "+method, nil)
+	}
+	return step, nil
+}
+
+// requireTOTPCipher refuses TOTP calls when TOTP_ENCRYPTION_KEY isn't set. Users who
+// turned 2FA on before the key was removed can still use their recovery codes.
+func (s *server) requireTOTPCipher(ctx context.Context, method string) error {
+	if s.totpCipher == nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "two-factor authentication is not set up: "+method, nil)
+	}
+	return nil
+}
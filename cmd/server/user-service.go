@@ -2,17 +2,28 @@ package server
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	authService "github.com/imhasandl/auth-service/cmd/auth"
-	postService "github.com/imhasandl/post-service/cmd/auth"
 	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/dataexport"
+	"github.com/imhasandl/user-service/internal/mail"
+	"github.com/imhasandl/user-service/internal/password"
+	"github.com/imhasandl/user-service/internal/purge"
 	"github.com/imhasandl/user-service/internal/rabbitmq"
+	"github.com/imhasandl/user-service/internal/totp"
+	"github.com/imhasandl/user-service/internal/username"
+	"github.com/imhasandl/user-service/internal/verification"
+	"github.com/imhasandl/user-service/internal/webauthn"
 	"github.com/streadway/amqp"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	helper "github.com/imhasandl/user-service/cmd/helper"
@@ -27,47 +38,107 @@ type UserServer interface {
 type server struct {
 	pb.UnimplementedUserServiceServer
 	db          *database.Queries
+	dbConn      *sql.DB
 	tokenSecret string
-	email       string
-	emailSecret string
+	mailer      mail.Mailer
+	templates   *mail.Templates
 	rabbitmq    *rabbitmq.RabbitMQ
+	verifier    verification.Hasher
+
+	// passwordResetURL is the page reset links point to. Without it, resets get no
+	// token and emails only carry the code.
+	passwordResetURL string
+
+	// passwordPolicy is checked by every RPC that sets a new password.
+	passwordPolicy password.Policy
+
+	// dataExports builds the archives asked for with RequestDataExport.
+	dataExports *dataexport.Worker
+
+	// totpCipher seals TOTP secrets before they are stored, nil if 2FA isn't set up.
+	// totpIssuer is the name authenticator apps show next to the codes.
+	totpCipher *totp.Cipher
+	totpIssuer string
+
+	// relyingParty is this service as passkeys see it, nil if passkeys aren't set up.
+	relyingParty *webauthn.RelyingParty
+
+	// allowDeleteAllUsers enables DeleteAllUsers, which must never run in production.
+	allowDeleteAllUsers bool
 }
 
 // NewServer creates and returns a new instance of the user service server.
 // It initializes the server with the provided repository, config, and optional handler.
-func NewServer(dbQueries *database.Queries, tokenSecret string, email string, emailSecret string, rabbitmq *rabbitmq.RabbitMQ) UserServer {
+func NewServer(
+	dbConn *sql.DB,
+	dbQueries *database.Queries,
+	tokenSecret string,
+	mailer mail.Mailer,
+	templates *mail.Templates,
+	passwordResetURL string,
+	rabbitmq *rabbitmq.RabbitMQ,
+	passwordPolicy password.Policy,
+	dataExports *dataexport.Worker,
+	totpCipher *totp.Cipher,
+	totpIssuer string,
+	relyingParty *webauthn.RelyingParty,
+	allowDeleteAllUsers bool,
+) UserServer {
 	return &server{
 		db:          dbQueries,
+		dbConn:      dbConn,
 		tokenSecret: tokenSecret,
-		email:       email,
-		emailSecret: emailSecret,
+		mailer:      mailer,
+		templates:   templates,
 		rabbitmq:    rabbitmq,
+		verifier:    verification.NewHasher(tokenSecret),
+
+		passwordResetURL:    passwordResetURL,
+		passwordPolicy:      passwordPolicy,
+		dataExports:         dataExports,
+		totpCipher:          totpCipher,
+		totpIssuer:          totpIssuer,
+		relyingParty:        relyingParty,
+		allowDeleteAllUsers: allowDeleteAllUsers,
 	}
 }
 
+// withTx runs fn inside a database transaction, committing only if fn succeeds.
+func (s *server) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(s.db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (s *server) GetUserByEmailOrUsername(
 	ctx context.Context,
 	req *pb.GetUserByEmailOrUsernameRequest,
 ) (*pb.GetUserByEmailOrUsernameResponse, error) {
-	userParams := database.GetUserByEmailOrUsernameParams{
-		Email:    req.GetIdentifier(),
-		Username: req.GetIdentifier(),
+	user, redirectedFrom, err := s.userByHandle(ctx, req.GetIdentifier())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "user not found: GetUserByEmailOrUsername", err)
 	}
-
-	user, err := s.db.GetUserByEmailOrUsername(ctx, userParams)
 	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: GetUserByEmailOrUsername", err)
 	}
 
+	// Only the user and admins may find an account by its email, so that nobody can
+	// probe which emails are registered.
+	v := callerFromContext(ctx).viewerOf(user.ID)
+	if v == viewerOther && redirectedFrom == "" && !strings.EqualFold(user.Username, req.GetIdentifier()) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "user not found: GetUserByEmailOrUsername", nil)
+	}
+
 	return &pb.GetUserByEmailOrUsernameResponse{
-		User: &pb.User{
-			Id:        user.ID.String(),
-			CreatedAt: timestamppb.New(user.CreatedAt),
-			UpdatedAt: timestamppb.New(user.UpdatedAt),
-			Email:     user.Email,
-			Username:  user.Username,
-			IsPremium: user.IsPremium,
-		},
+		User:           newPbUser(user, v),
+		RedirectedFrom: redirectedFrom,
 	}, nil
 }
 
@@ -78,33 +149,22 @@ func (s *server) GetUserByID(ctx context.Context, req *pb.GetUserByIDRequest) (*
 	}
 
 	user, err := s.db.GetUserById(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "user not found: GetUserByID", err)
+	}
 	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: GetUserByID", err)
 	}
 
 	return &pb.GetUserByIDResponse{
-		User: &pb.User{
-			Id:               user.ID.String(),
-			CreatedAt:        timestamppb.New(user.CreatedAt),
-			UpdatedAt:        timestamppb.New(user.UpdatedAt),
-			Email:            user.Email,
-			Username:         user.Username,
-			IsPremium:        user.IsPremium,
-			VerificationCode: user.VerificationCode,
-			IsVerified:       user.IsVerified,
-		},
+		User: newPbUser(user, callerFromContext(ctx).viewerOf(user.ID)),
 	}, nil
 }
 
 func (s *server) GetUserByToken(ctx context.Context, req *pb.GetUserByTokenRequest) (*pb.GetUserByTokenResponse, error) {
-	accessToken, err := postService.GetBearerTokenFromGrpc(ctx)
+	userID, err := requireUserID(ctx, "GetUserByToken")
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't get token from header: GetUserByToken", err)
-	}
-
-	userID, err := postService.ValidateJWT(accessToken, s.tokenSecret)
-	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Unauthenticated, "can't validate token: GetUserByToken", err)
+		return nil, err
 	}
 
 	user, err := s.db.GetUserById(ctx, userID)
@@ -113,88 +173,284 @@ func (s *server) GetUserByToken(ctx context.Context, req *pb.GetUserByTokenReque
 	}
 
 	return &pb.GetUserByTokenResponse{
-		User: &pb.User{
-			Id:               user.ID.String(),
-			CreatedAt:        timestamppb.New(user.CreatedAt),
-			UpdatedAt:        timestamppb.New(user.UpdatedAt),
-			Email:            user.Email,
-			Username:         user.Username,
-			IsPremium:        user.IsPremium,
-			VerificationCode: user.VerificationCode,
-			IsVerified:       user.IsVerified,
-		},
+		User: newPbUser(user, viewerSelf),
 	}, nil
 }
 
 func (s *server) GetAllUsers(ctx context.Context, req *pb.GetAllUsersRequest) (*pb.GetAllUsersResponse, error) {
-	users, err := s.db.GetAllUsers(ctx)
+	cursor, err := helper.DecodeKeysetToken(req.GetPageToken())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "invalid page token: GetAllUsers", err)
+	}
+	pageSize := helper.PageSize(req.GetPageSize())
+	filter := newUserFilter(req.GetFilter())
+
+	users, err := s.listUsers(ctx, req.GetSortOrder(), filter, cursor, pageSize+1)
 	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get users from db: GetAllUsers", err)
 	}
 
+	totalSize, err := s.db.CountUsers(ctx, database.CountUsersParams(filter))
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't count users in db: GetAllUsers", err)
+	}
+
+	nextPageToken := ""
+	if len(users) > int(pageSize) {
+		users = users[:pageSize]
+		last := users[len(users)-1]
+		nextPageToken = helper.EncodeKeysetToken(last.CreatedAt, last.ID)
+	}
+
+	caller := callerFromContext(ctx)
 	pbUsers := make([]*pb.User, len(users))
 	for i, user := range users {
-		pbUsers[i] = &pb.User{
-			Id:               user.ID.String(),
-			CreatedAt:        timestamppb.New(user.CreatedAt),
-			UpdatedAt:        timestamppb.New(user.UpdatedAt),
-			Email:            user.Email,
-			Username:         user.Username,
-			IsPremium:        user.IsPremium,
-			VerificationCode: user.VerificationCode,
-			IsVerified:       user.IsVerified,
-		}
+		pbUsers[i] = newPbUser(user, caller.viewerOf(user.ID))
 	}
 
 	return &pb.GetAllUsersResponse{
-		Users: pbUsers,
+		Users:         pbUsers,
+		NextPageToken: nextPageToken,
+		TotalSize:     totalSize,
 	}, nil
 }
 
-func (s *server) ChangeUsername(ctx context.Context, req *pb.ChangeUsernameRequest) (*pb.ChangeUsernameResponse, error) {
-	accessToken, err := postService.GetBearerTokenFromGrpc(ctx)
+// userFilter holds the optional listing filters in the shape the users queries expect.
+type userFilter struct {
+	IsPremium     sql.NullBool
+	IsVerified    sql.NullBool
+	CreatedAfter  sql.NullTime
+	CreatedBefore sql.NullTime
+}
+
+func newUserFilter(f *pb.UserFilter) userFilter {
+	var filter userFilter
+	if f == nil {
+		return filter
+	}
+	if f.IsPremium != nil {
+		filter.IsPremium = sql.NullBool{Bool: f.GetIsPremium(), Valid: true}
+	}
+	if f.IsVerified != nil {
+		filter.IsVerified = sql.NullBool{Bool: f.GetIsVerified(), Valid: true}
+	}
+	if f.GetCreatedAfter() != nil {
+		filter.CreatedAfter = sql.NullTime{Time: f.GetCreatedAfter().AsTime(), Valid: true}
+	}
+	if f.GetCreatedBefore() != nil {
+		filter.CreatedBefore = sql.NullTime{Time: f.GetCreatedBefore().AsTime(), Valid: true}
+	}
+	return filter
+}
+
+// keysetArgs converts a decoded page token into the nullable cursor arguments of
+// the keyset-paginated queries. A nil cursor selects the first page.
+func keysetArgs(cursor *helper.Keyset) (sql.NullTime, uuid.NullUUID) {
+	if cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: cursor.ID, Valid: true}
+}
+
+// listUsers returns up to limit users after cursor in the requested sort order.
+func (s *server) listUsers(
+	ctx context.Context,
+	order pb.UserSortOrder,
+	filter userFilter,
+	cursor *helper.Keyset,
+	limit int32,
+) ([]database.User, error) {
+	cursorCreatedAt, cursorID := keysetArgs(cursor)
+
+	if order == pb.UserSortOrder_CREATED_AT_ASC {
+		return s.db.ListUsersOldestFirst(ctx, database.ListUsersOldestFirstParams{
+			IsPremium:       filter.IsPremium,
+			IsVerified:      filter.IsVerified,
+			CreatedAfter:    filter.CreatedAfter,
+			CreatedBefore:   filter.CreatedBefore,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        limit,
+		})
+	}
+
+	return s.db.ListUsersNewestFirst(ctx, database.ListUsersNewestFirstParams{
+		IsPremium:       filter.IsPremium,
+		IsVerified:      filter.IsVerified,
+		CreatedAfter:    filter.CreatedAfter,
+		CreatedBefore:   filter.CreatedBefore,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        limit,
+	})
+}
+
+// exportBatchSize is how many rows ExportUsers reads from the database per round trip.
+const exportBatchSize = 500
+
+// ExportUsers streams every user matching the filter in id order. Rows are read in
+// keyset batches on id instead of one long-running query, so a slow consumer never
+// holds a transaction open and a broken stream can resume from the last id received.
+func (s *server) ExportUsers(req *pb.ExportUsersRequest, stream pb.UserService_ExportUsersServer) error {
+	ctx := stream.Context()
+
+	var afterID uuid.NullUUID
+	if req.GetCheckpointId() != "" {
+		checkpointID, err := uuid.Parse(req.GetCheckpointId())
+		if err != nil {
+			return helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse checkpoint id: ExportUsers", err)
+		}
+		afterID = uuid.NullUUID{UUID: checkpointID, Valid: true}
+	}
+
+	filter := newUserFilter(req.GetFilter())
+	for {
+		users, err := s.db.ExportUsersBatch(ctx, database.ExportUsersBatchParams{
+			IsPremium:     filter.IsPremium,
+			IsVerified:    filter.IsVerified,
+			CreatedAfter:  filter.CreatedAfter,
+			CreatedBefore: filter.CreatedBefore,
+			AfterID:       afterID,
+			BatchSize:     exportBatchSize,
+		})
+		if err != nil {
+			return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get users from db: ExportUsers", err)
+		}
+
+		if err := sendExportBatch(stream, users); err != nil {
+			return err
+		}
+
+		if len(users) < exportBatchSize {
+			return nil
+		}
+		afterID = uuid.NullUUID{UUID: users[len(users)-1].ID, Valid: true}
+	}
+}
+
+// sendExportBatch sends users one by one, stopping as soon as the client goes away.
+func sendExportBatch(stream pb.UserService_ExportUsersServer, users []database.User) error {
+	ctx := stream.Context()
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return helper.RespondWithErrorGRPC(ctx, status.FromContextError(err).Code(), "export stopped: ExportUsers", err)
+		}
+
+		// Exports feed internal analytics and search indexing, which need every field.
+		err := stream.Send(newPbUser(user, viewerAdmin))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// likeEscaper escapes LIKE wildcards so a search query is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *server) SearchUsers(ctx context.Context, req *pb.SearchUsersRequest) (*pb.SearchUsersResponse, error) {
+	query := strings.TrimSpace(req.GetQuery())
+	if query == "" {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "query must not be empty: SearchUsers", nil)
+	}
+
+	offset, err := helper.DecodeOffsetToken(req.GetPageToken())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "invalid page token: SearchUsers", err)
+	}
+	pageSize := helper.PageSize(req.GetPageSize())
+
+	searchUsersParams := database.SearchUsersParams{
+		Prefix:     likeEscaper.Replace(query) + "%",
+		Query:      query,
+		MatchEmail: callerFromContext(ctx).admin, // exact email lookup is reserved for admins
+		PageSize:   pageSize + 1,
+		PageOffset: offset,
+	}
+
+	users, err := s.db.SearchUsers(ctx, searchUsersParams)
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't get token from header: ChangeUsername", err)
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't search users in db: SearchUsers", err)
+	}
+
+	nextPageToken := ""
+	if len(users) > int(pageSize) {
+		users = users[:pageSize]
+		nextPageToken = helper.EncodeOffsetToken(offset + pageSize)
+	}
+
+	pbUsers := make([]*pb.PublicUser, len(users))
+	for i, user := range users {
+		pbUsers[i] = newPbPublicUser(user)
 	}
 
-	userID, err := postService.ValidateJWT(accessToken, s.tokenSecret)
+	return &pb.SearchUsersResponse{
+		Users:         pbUsers,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+func (s *server) ChangeUsername(ctx context.Context, req *pb.ChangeUsernameRequest) (*pb.ChangeUsernameResponse, error) {
+	userID, err := requireUserID(ctx, "ChangeUsername")
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Unauthenticated, "you are not allowed to change username: ChangeUsername", err)
+		return nil, err
 	}
 
-	changeUsernameParams := database.ChangeUsernameParams{
-		ID:       userID,
-		Username: req.GetUsername(),
+	name := strings.TrimSpace(req.GetUsername())
+	if err := username.Validate(name); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, err.Error()+": ChangeUsername", err)
 	}
 
-	user, err := s.db.ChangeUsername(ctx, changeUsernameParams)
+	user, err := s.db.GetUserById(ctx, userID)
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't change username in db: ChangeUsername", err)
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: ChangeUsername", err)
+	}
+
+	if name != user.Username {
+		if err := s.usernameChangeAllowed(ctx, user, name); err != nil {
+			return nil, err
+		}
+
+		user, err = s.changeUsername(ctx, user, name)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &pb.ChangeUsernameResponse{
-		User: &pb.User{
-			Id:               user.ID.String(),
-			CreatedAt:        timestamppb.New(user.CreatedAt),
-			UpdatedAt:        timestamppb.New(user.UpdatedAt),
-			Email:            user.Email,
-			Username:         user.Username,
-			IsPremium:        user.IsPremium,
-			VerificationCode: user.VerificationCode,
-			IsVerified:       user.IsVerified,
-		},
+		User: newPbUser(user, viewerSelf),
 	}, nil
 }
 
+// Rate limits of ChangePassword, per rateLimitWindow. It checks the current
+// password, so a stolen session can't be used to guess it.
+const (
+	maxPasswordChangesPerUser = 10
+	maxPasswordChangesPerIP   = 50
+)
+
 func (s *server) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
-	accessToken, err := postService.GetBearerTokenFromGrpc(ctx)
+	userID, err := requireUserID(ctx, "ChangePassword")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.GetUserById(ctx, userID)
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't get authorization token from header: ChangePassword", err)
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: ChangePassword", err)
 	}
 
-	userID, err := postService.ValidateJWT(accessToken, s.tokenSecret)
+	err = s.limitAttempts(ctx, "change-password", userID.String(), maxPasswordChangesPerUser, maxPasswordChangesPerIP, "ChangePassword")
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Unauthenticated, "you are not allowed to delete user: ChangePassword", err)
+		return nil, err
+	}
+
+	if err := authService.CheckPassword(user.Password, req.GetCurrentPassword()); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "current password is not correct: ChangePassword", err)
+	}
+
+	if err := s.checkNewPassword(ctx, user, req.GetPassword(), "ChangePassword"); err != nil {
+		return nil, err
 	}
 
 	hashedPassword, err := authService.HashPassword(req.GetPassword())
@@ -202,12 +458,13 @@ func (s *server) ChangePassword(ctx context.Context, req *pb.ChangePasswordReque
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't hash password: ChangePassword", err)
 	}
 
-	changePasswordParams := database.ChangePasswordParams{
-		ID:       userID,
-		Password: hashedPassword,
+	if err := s.requireSecondFactor(ctx, userID, req.GetSecondFactor(), "ChangePassword"); err != nil {
+		return nil, err
 	}
 
-	err = s.db.ChangePassword(ctx, changePasswordParams)
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		return replacePassword(ctx, q, user, hashedPassword)
+	})
 	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't change password: ChangePassword", err)
 	}
@@ -218,43 +475,96 @@ func (s *server) ChangePassword(ctx context.Context, req *pb.ChangePasswordReque
 }
 
 func (s *server) SubscribeUser(ctx context.Context, req *pb.SubscribeUserRequest) (*pb.SubscribeUserResponse, error) {
-	accessToken, err := postService.GetBearerTokenFromGrpc(ctx)
+	subscriberUserID, err := requireUserID(ctx, "SubscribeUser")
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't token from ctx - SubscribeUser", err)
+		return nil, err
 	}
 
-	subscriberUserID, err := postService.ValidateJWT(accessToken, s.tokenSecret)
+	subscribedUserID, err := uuid.Parse(req.GetUserId())
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't validate provided token - SubscribeUser", err)
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user's uuid - SubscribeUser", err)
 	}
 
-	subscribedUserID, err := uuid.Parse(req.GetUserId())
+	if subscriberUserID == subscribedUserID {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "you can't subscribe to yourself - SubscribeUser", nil)
+	}
+
+	subscribedUser, err := s.subscriptionTarget(ctx, subscriberUserID, subscribedUserID)
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user's uuid - SubscribeUser", err)
+		return nil, err
+	}
+
+	if subscribedUser.IsPrivate {
+		return s.requestToFollow(ctx, subscriberUserID, subscribedUserID)
 	}
 
-	subscribeUserParamsParams := database.SubscribeUserParams{
-		ID:          subscribedUserID,
-		ArrayAppend: subscriberUserID,
+	followUserParams := database.FollowUserParams{
+		FollowerID: subscriberUserID,
+		FolloweeID: subscribedUserID,
 	}
 
-	err = s.db.SubscribeUser(ctx, subscribeUserParamsParams)
+	inserted, err := s.db.FollowUser(ctx, followUserParams)
 	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't sub to user - SubscribeUser", err)
 	}
 
+	// Subscribing twice is a no-op and must not notify the user again.
+	if inserted == 0 {
+		return &pb.SubscribeUserResponse{
+			Status: true,
+		}, nil
+	}
+
+	content := fmt.Sprintf("This user %v subscribed on you", subscriberUserID)
+	if err := s.publishNotification(subscriberUserID, subscribedUserID, content); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't publish message to RabbitMQ - SubscribeUser", err)
+	}
+
+	return &pb.SubscribeUserResponse{
+		Status: true,
+	}, nil
+}
+
+// subscriptionTarget loads subscribedUserID, making sure that neither user blocked the other.
+func (s *server) subscriptionTarget(ctx context.Context, subscriberUserID, subscribedUserID uuid.UUID) (database.User, error) {
+	subscribedUser, err := s.db.GetUserById(ctx, subscribedUserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.User{}, helper.RespondWithErrorGRPC(ctx, codes.NotFound, "user to subscribe to not found - SubscribeUser", err)
+		}
+		return database.User{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db - SubscribeUser", err)
+	}
+
+	isBlockedEitherWayParams := database.IsBlockedEitherWayParams{
+		UserA: subscriberUserID,
+		UserB: subscribedUserID,
+	}
+
+	blocked, err := s.db.IsBlockedEitherWay(ctx, isBlockedEitherWayParams)
+	if err != nil {
+		return database.User{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't check blocks in db - SubscribeUser", err)
+	}
+	if blocked {
+		return database.User{}, helper.RespondWithErrorGRPC(ctx, codes.PermissionDenied, "you can't subscribe to this user - SubscribeUser", nil)
+	}
+
+	return subscribedUser, nil
+}
+
+// publishNotification asks the notification service to show content to receiverID.
+func (s *server) publishNotification(senderID, receiverID uuid.UUID, content string) error {
 	messageJSON, err := json.Marshal(map[string]string{
 		"title":           "New Notification",
-		"sender_username": subscribedUserID.String(),
-		"receiver_id":     subscribedUserID.String(),
-		"content":         fmt.Sprintf("This user %v subscribed on you", subscriberUserID),
+		"sender_username": senderID.String(),
+		"receiver_id":     receiverID.String(),
+		"content":         content,
 		"sent_at":         time.Now().GoString(),
 	})
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't send subscribe notification - SubscribeUser", err)
+		return err
 	}
 
-	err = s.rabbitmq.Channel.Publish(
+	return s.rabbitmq.Channel.Publish(
 		rabbitmq.ExchangeName, // exchange
 		rabbitmq.RoutingKey,   // routing key
 		false,                 // mandatory
@@ -263,24 +573,12 @@ func (s *server) SubscribeUser(ctx context.Context, req *pb.SubscribeUserRequest
 			ContentType: "application/json",
 			Body:        messageJSON,
 		})
-	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't publish message to RabbitMQ - SubscribeUser", err)
-	}
-
-	return &pb.SubscribeUserResponse{
-		Status: true,
-	}, nil
 }
 
 func (s *server) UnsubscribeUser(ctx context.Context, req *pb.UnsubscribeUserRequest) (*pb.UnsubscribeUserReponse, error) {
-	accessToken, err := postService.GetBearerTokenFromGrpc(ctx)
-	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't token from ctx - UnsubscribeUser", err)
-	}
-
-	unSubscriberUserID, err := postService.ValidateJWT(accessToken, s.tokenSecret)
+	unSubscriberUserID, err := requireUserID(ctx, "UnsubscribeUser")
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't validate provided token - UnsubscribeUser", err)
+		return nil, err
 	}
 
 	unSubscribedUserID, err := uuid.Parse(req.GetUserId())
@@ -288,30 +586,38 @@ func (s *server) UnsubscribeUser(ctx context.Context, req *pb.UnsubscribeUserReq
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user's uuid - UnsubscribeUser", err)
 	}
 
-	unSubscribeUserParamsParams := database.UnsubscribeUserParams{
-		ID:          unSubscribedUserID,
-		ArrayRemove: unSubscriberUserID,
+	unfollowUserParams := database.UnfollowUserParams{
+		FollowerID: unSubscriberUserID,
+		FolloweeID: unSubscribedUserID,
 	}
 
-	err = s.db.UnsubscribeUser(ctx, unSubscribeUserParamsParams)
+	err = s.db.UnfollowUser(ctx, unfollowUserParams)
 	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't unsub to user - UnsubscribeUser", err)
 	}
 
+	// Unsubscribing also withdraws a follow request that is still pending.
+	deleteFollowRequestParams := database.DeleteFollowRequestParams{
+		RequesterID: unSubscriberUserID,
+		TargetID:    unSubscribedUserID,
+	}
+
+	if _, err := s.db.DeleteFollowRequest(ctx, deleteFollowRequestParams); err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't withdraw follow request - UnsubscribeUser", err)
+	}
+
 	return &pb.UnsubscribeUserReponse{
 		Status: true,
 	}, nil
 }
 
+// DeleteUser deletes the account of the caller and signs them out everywhere. The
+// account stays restorable with RestoreAccount for purge.GracePeriod, after which the
+// purge worker removes it for good.
 func (s *server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
-	accessToken, err := postService.GetBearerTokenFromGrpc(ctx)
-	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't get authorization token from header: DeleteUser", err)
-	}
-
-	userID, err := postService.ValidateJWT(accessToken, s.tokenSecret)
+	userID, err := requireUserID(ctx, "DeleteUser")
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Unauthenticated, "you are not allowed to delete user: DeleteUser", err)
+		return nil, err
 	}
 
 	user, err := s.db.GetUserById(ctx, userID)
@@ -327,12 +633,29 @@ func (s *server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "you must submit 'SUBMIT' to delete your account", nil)
 	}
 
-	if err = s.db.DeleteUser(ctx, userID); err != nil {
+	if err := s.requireSecondFactor(ctx, userID, req.GetSecondFactor(), "DeleteUser"); err != nil {
+		return nil, err
+	}
+
+	deletedAt := time.Now().UTC()
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		softDeleteUserParams := database.SoftDeleteUserParams{
+			ID:        userID,
+			DeletedAt: sql.NullTime{Time: deletedAt, Valid: true},
+		}
+		if err := q.SoftDeleteUser(ctx, softDeleteUserParams); err != nil {
+			return err
+		}
+
+		return q.DeleteUserRefreshTokens(ctx, userID)
+	})
+	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't delete user from db: DeleteUser", err)
 	}
 
 	return &pb.DeleteUserResponse{
-		Status: "success",
+		Status:     "success",
+		PurgeAfter: timestamppb.New(deletedAt.Add(purge.GracePeriod)),
 	}, nil
 }
 
@@ -341,14 +664,9 @@ func (s *server) SendVerificationCode(
 	req *pb.SendVerificationCodeRequest,
 ) (*pb.SendVerificationCodeResponse, error) {
 
-	accessToken, err := postService.GetBearerTokenFromGrpc(ctx)
+	userID, err := requireUserID(ctx, "SendVerificationCode")
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't get authorization token from header: SendVerificationCode", err)
-	}
-
-	userID, err := postService.ValidateJWT(accessToken, s.tokenSecret)
-	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Unauthenticated, "you are not allowed to reset password: SendVerificationCode", err)
+		return nil, err
 	}
 
 	user, err := s.db.GetUserById(ctx, userID)
@@ -356,40 +674,48 @@ func (s *server) SendVerificationCode(
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: SendVerificationCode", err)
 	}
 
-	verificationCode, err := authService.GenerateVerificationCode()
-	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't generate verification code: SendVerificationCode", err)
+	if err := s.issueVerificationCode(ctx, user, "SendVerificationCode"); err != nil {
+		return nil, err
 	}
 
-	sendResetVerificationCodeParams := database.SendResetVerificationCodeParams{
-		ID:               userID,
-		VerificationCode: verificationCode,
-	}
+	return &pb.SendVerificationCodeResponse{
+		Status: "Verification code sent",
+	}, nil
+}
 
-	err = s.db.SendResetVerificationCode(ctx, sendResetVerificationCodeParams)
+// SendVerificationCodeAgain replaces a pending verification code with a new one, at
+// most once per verification.ResendCooldown.
+func (s *server) SendVerificationCodeAgain(
+	ctx context.Context,
+	req *pb.SendVerificationCodeAgainRequest,
+) (*pb.SendVerificationCodeAgainResponse, error) {
+	userID, err := requireUserID(ctx, "SendVerificationCodeAgain")
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't send verification code: SendVerificationCode", err)
+		return nil, err
 	}
 
-	err = authService.SendVerificationEmail(user.Email, s.email, s.emailSecret, verificationCode)
+	user, err := s.db.GetUserById(ctx, userID)
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't send verification email: SendVerificationCode", err)
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: SendVerificationCodeAgain", err)
 	}
 
-	return &pb.SendVerificationCodeResponse{
-		Status: "Verification code sent",
+	if user.VerificationCodeHash == "" {
+		return nil, verificationError(ctx, verification.ErrNoCode, "SendVerificationCodeAgain")
+	}
+
+	if err := s.issueVerificationCode(ctx, user, "SendVerificationCodeAgain"); err != nil {
+		return nil, err
+	}
+
+	return &pb.SendVerificationCodeAgainResponse{
+		Status: "Verification code sent again",
 	}, nil
 }
 
 func (s *server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
-	accessToken, err := postService.GetBearerTokenFromGrpc(ctx)
-	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't get authorization token from header: ResetPassword", err)
-	}
-
-	userID, err := postService.ValidateJWT(accessToken, s.tokenSecret)
+	userID, err := requireUserID(ctx, "ResetPassword")
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Unauthenticated, "you are not allowed to reset password: ResetPassword", err)
+		return nil, err
 	}
 
 	user, err := s.db.GetUserById(ctx, userID)
@@ -397,26 +723,30 @@ func (s *server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get user from db: ResetPassword", err)
 	}
 
-	if req.GetVerificationCode() != user.VerificationCode {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "verification code is not correct", nil)
+	if err := s.checkVerificationCode(ctx, user, req.GetVerificationCode(), "ResetPassword"); err != nil {
+		return nil, err
 	}
 
-	err = s.db.VerifyVerificationCode(ctx, userID)
-	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't verify verification code: ResetPassword", err)
+	if err := s.checkNewPassword(ctx, user, req.GetNewPassword(), "ResetPassword"); err != nil {
+		return nil, err
 	}
 
-	newPassword, err := authService.HashPassword(req.NewPassword)
+	newPassword, err := authService.HashPassword(req.GetNewPassword())
 	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't hash new password: ResetPassword", err)
 	}
 
-	resetPasswordParams := database.ResetPasswordParams{
-		ID:       userID,
-		Password: newPassword,
+	if err := s.requireSecondFactor(ctx, userID, req.GetSecondFactor(), "ResetPassword"); err != nil {
+		return nil, err
 	}
 
-	err = s.db.ResetPassword(ctx, resetPasswordParams)
+	err = s.withTx(ctx, func(q *database.Queries) error {
+		if err := q.ClearVerificationCode(ctx, userID); err != nil {
+			return err
+		}
+
+		return replacePassword(ctx, q, user, newPassword)
+	})
 	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't reset password: ResetPassword", err)
 	}
@@ -427,6 +757,10 @@ func (s *server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest
 }
 
 func (s *server) DeleteAllUsers(ctx context.Context, req *pb.DeleteAllUsersRequest) (*pb.DeleteAllUsersResponse, error) {
+	if !s.allowDeleteAllUsers {
+		return nil, helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "deleting all users is disabled in this environment: DeleteAllUsers", nil)
+	}
+
 	err := s.db.DeleteAllUsers(ctx)
 	if err != nil {
 		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't delete user from db: DeleteAllUsers", err)
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/username"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+)
+
+// usernameChangeAllowed checks the cooldown of user and that nobody else holds name
+// through a redirect.
+func (s *server) usernameChangeAllowed(ctx context.Context, user database.User, name string) error {
+	now := time.Now().UTC()
+
+	if next := username.NextChange(user.UsernameChangedAt.Time); now.Before(next) {
+		return helper.RespondWithErrorGRPC(ctx, codes.FailedPrecondition, "username can be changed again after "+next.Format(time.RFC3339)+": ChangeUsername", nil)
+	}
+
+	getUsernameRedirectParams := database.GetUsernameRedirectParams{
+		Username: name,
+		Now:      now,
+	}
+	holderID, err := s.db.GetUsernameRedirect(ctx, getUsernameRedirectParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't check username history: ChangeUsername", err)
+	}
+	if holderID != user.ID {
+		return helper.RespondWithErrorGRPC(ctx, codes.AlreadyExists, "username is taken: ChangeUsername", nil)
+	}
+
+	return nil
+}
+
+// changeUsername renames user to name and keeps the old handle pointing at them for
+// username.RedirectPeriod. Taking back a handle of your own ends its redirect.
+func (s *server) changeUsername(ctx context.Context, user database.User, name string) (database.User, error) {
+	now := time.Now().UTC()
+
+	var updated database.User
+	err := s.withTx(ctx, func(q *database.Queries) error {
+		if err := q.ReclaimUsername(ctx, name); err != nil {
+			return err
+		}
+
+		changeUsernameParams := database.ChangeUsernameParams{
+			ID:                user.ID,
+			Username:          name,
+			UsernameChangedAt: sql.NullTime{Time: now, Valid: true},
+		}
+		var err error
+		updated, err = q.ChangeUsername(ctx, changeUsernameParams)
+		if err != nil {
+			return err
+		}
+
+		if strings.EqualFold(user.Username, name) {
+			return nil
+		}
+
+		releaseUsernameParams := database.ReleaseUsernameParams{
+			Username:      user.Username,
+			UserID:        user.ID,
+			ReleasedAt:    now,
+			RedirectUntil: now.Add(username.RedirectPeriod),
+		}
+		return q.ReleaseUsername(ctx, releaseUsernameParams)
+	})
+	if helper.IsUniqueViolation(err) {
+		return database.User{}, helper.RespondWithErrorGRPC(ctx, codes.AlreadyExists, "username is taken: ChangeUsername", err)
+	}
+	if err != nil {
+		return database.User{}, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't change username in db: ChangeUsername", err)
+	}
+
+	return updated, nil
+}
+
+// userByHandle finds the user with the given email or username. A username released
+// less than username.RedirectPeriod ago finds its former owner, and is returned as
+// redirectedFrom.
+func (s *server) userByHandle(ctx context.Context, identifier string) (user database.User, redirectedFrom string, err error) {
+	user, err = s.userByIdentifier(ctx, identifier)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return user, "", err
+	}
+
+	getUsernameRedirectParams := database.GetUsernameRedirectParams{
+		Username: identifier,
+		Now:      time.Now().UTC(),
+	}
+	userID, err := s.db.GetUsernameRedirect(ctx, getUsernameRedirectParams)
+	if err != nil {
+		return database.User{}, "", err
+	}
+
+	user, err = s.db.GetUserById(ctx, userID)
+	return user, identifier, err
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	authService "github.com/imhasandl/auth-service/cmd/auth"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/mail"
+	"github.com/imhasandl/user-service/internal/verification"
+	"google.golang.org/grpc/codes"
+
+	helper "github.com/imhasandl/user-service/cmd/helper"
+)
+
+// issueVerificationCode emails user a new verification code and stores its hash,
+// unless the flow is locked or a code was sent too recently.
+func (s *server) issueVerificationCode(ctx context.Context, user database.User, method string) error {
+	now := time.Now().UTC()
+	if err := verification.CanIssue(verificationState(user), now); err != nil {
+		return verificationError(ctx, err, method)
+	}
+
+	verificationCode, err := authService.GenerateVerificationCode()
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't generate verification code: "+method, err)
+	}
+
+	storeVerificationCodeParams := database.StoreVerificationCodeParams{
+		CodeHash:   s.verifier.Hash(user.ID, verificationCode),
+		ExpiresAt:  now.Add(verification.CodeTTL),
+		SentAt:     sql.NullTime{Time: now, Valid: true},
+		ID:         user.ID,
+		SentBefore: sql.NullTime{Time: now.Add(-verification.ResendCooldown), Valid: true},
+	}
+
+	// The cooldown is checked again in the update, so two concurrent requests can't
+	// both send a code.
+	stored, err := s.db.StoreVerificationCode(ctx, storeVerificationCodeParams)
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't store verification code: "+method, err)
+	}
+	if stored == 0 {
+		return verificationError(ctx, verification.ErrCooldown, method)
+	}
+
+	err = s.sendMail(ctx, user.Email, mail.TemplateVerificationCode, codeMail{Code: formatCode(verificationCode)})
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't send verification email: "+method, err)
+	}
+
+	return nil
+}
+
+// checkVerificationCode counts an attempt at the verification code of user and checks it.
+func (s *server) checkVerificationCode(ctx context.Context, user database.User, code int32, method string) error {
+	attempts, err := s.db.RecordVerificationAttempt(ctx, user.ID)
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't record verification attempt: "+method, err)
+	}
+
+	err = s.verifier.Check(verificationState(user), user.ID, code, attempts, time.Now().UTC())
+	if err != nil {
+		return verificationError(ctx, err, method)
+	}
+
+	return nil
+}
+
+func verificationState(user database.User) verification.State {
+	return verification.State{
+		Hash:      user.VerificationCodeHash,
+		ExpiresAt: user.VerificationExpireTime,
+		Attempts:  user.VerificationAttempts,
+		SentAt:    user.VerificationSentAt.Time,
+	}
+}
+
+// verificationError maps the errors of the verification package to gRPC codes.
+func verificationError(ctx context.Context, err error, method string) error {
+	code := codes.InvalidArgument
+	switch {
+	case errors.Is(err, verification.ErrTooManyAttempts), errors.Is(err, verification.ErrCooldown):
+		code = codes.ResourceExhausted
+	case errors.Is(err, verification.ErrNoCode), errors.Is(err, verification.ErrExpired):
+		code = codes.FailedPrecondition
+	}
+	return helper.RespondWithErrorGRPC(ctx, code, err.Error()+": "+method, err)
+}
@@ -0,0 +1,251 @@
+// Package auth authenticates incoming gRPC calls in one place. An interceptor checks
+// the bearer token against a per-method policy and stores the caller's identity in the
+// request context, where handlers read it back with FromContext.
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	postService "github.com/imhasandl/post-service/cmd/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Policy is who may call a method.
+type Policy int
+
+const (
+	// Public methods accept anonymous callers. A valid token still identifies the caller.
+	Public Policy = iota
+	// User methods need a valid token.
+	User
+	// Moderator methods need a valid token belonging to a moderator or an administrator.
+	Moderator
+	// Admin methods need a valid token belonging to an administrator.
+	Admin
+)
+
+// Role is what a user is allowed to do, as stored in users.role.
+type Role string
+
+// Roles a user can have.
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// ParseRole checks that role is one of the known roles.
+func ParseRole(role string) (Role, error) {
+	switch Role(role) {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return Role(role), nil
+	default:
+		return "", errors.New("unknown role " + role)
+	}
+}
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	UserID uuid.UUID
+	Role   Role
+}
+
+// IsAdmin reports whether the caller is an administrator.
+func (i Identity) IsAdmin() bool {
+	return i.Role == RoleAdmin
+}
+
+// satisfies reports whether the caller may call a method with policy.
+func (i Identity) satisfies(policy Policy) bool {
+	switch policy {
+	case Moderator:
+		return i.Role == RoleModerator || i.Role == RoleAdmin
+	case Admin:
+		return i.Role == RoleAdmin
+	default:
+		return true
+	}
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying identity.
+func NewContext(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the identity stored by the interceptor. It reports false for
+// anonymous callers of public methods.
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+// ErrUnknownUser is returned by a RoleResolver when the user behind a valid token no
+// longer exists.
+var ErrUnknownUser = errors.New("unknown user")
+
+// RoleResolver returns the current role of userID.
+type RoleResolver func(ctx context.Context, userID uuid.UUID) (Role, error)
+
+// VerifiedResolver reports whether userID has verified their email.
+type VerifiedResolver func(ctx context.Context, userID uuid.UUID) (bool, error)
+
+// Interceptor enforces a policy table on every call to the services it guards.
+type Interceptor struct {
+	tokenSecret string
+	service     string
+	policies    map[string]Policy
+	roleOf      RoleResolver
+
+	// needsVerified holds the methods that only accept callers with a verified email.
+	needsVerified map[string]bool
+	isVerified    VerifiedResolver
+}
+
+// Option changes how an Interceptor checks calls.
+type Option func(*Interceptor)
+
+// RequireVerified makes the given full methods refuse callers whose email isn't
+// verified, with FailedPrecondition. isVerified is only called for those methods.
+func RequireVerified(isVerified VerifiedResolver, fullMethods ...string) Option {
+	return func(i *Interceptor) {
+		i.isVerified = isVerified
+		for _, method := range fullMethods {
+			i.needsVerified[method] = true
+		}
+	}
+}
+
+// NewInterceptor creates an interceptor for the gRPC service named service, such as
+// "user.UserService". Policies are keyed by full method name, e.g.
+// "/user.UserService/GetUserByID". Methods of that service missing from the table are
+// refused, so a new RPC is closed until someone decides who may call it. Calls to
+// other services, like server reflection, pass through untouched. Roles are looked up
+// on every authenticated call, so granting or revoking one takes effect immediately.
+func NewInterceptor(tokenSecret, service string, policies map[string]Policy, roleOf RoleResolver, opts ...Option) *Interceptor {
+	i := &Interceptor{
+		tokenSecret:   tokenSecret,
+		service:       service,
+		policies:      policies,
+		roleOf:        roleOf,
+		needsVerified: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Unary returns the interceptor for unary RPCs.
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := i.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns the interceptor for streaming RPCs.
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.authorize(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authorize applies the policy of fullMethod and returns ctx with the caller's identity.
+func (i *Interceptor) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	if !strings.HasPrefix(fullMethod, "/"+i.service+"/") {
+		return ctx, nil
+	}
+
+	policy, ok := i.policies[fullMethod]
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "no access policy for %s", fullMethod)
+	}
+
+	identity, err := i.authenticate(ctx)
+	if err != nil {
+		if policy == Public {
+			return ctx, nil
+		}
+		return nil, err
+	}
+
+	if !identity.satisfies(policy) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s role required", requiredRole(policy))
+	}
+
+	if err := i.checkVerified(ctx, fullMethod, identity); err != nil {
+		return nil, err
+	}
+
+	return NewContext(ctx, identity), nil
+}
+
+// checkVerified refuses callers without a verified email if fullMethod needs one.
+func (i *Interceptor) checkVerified(ctx context.Context, fullMethod string, identity Identity) error {
+	if !i.needsVerified[fullMethod] {
+		return nil
+	}
+
+	verified, err := i.isVerified(ctx, identity.UserID)
+	if err != nil {
+		return status.Error(codes.Internal, "can't check whether caller is verified")
+	}
+	if !verified {
+		return status.Error(codes.FailedPrecondition, "verified email required")
+	}
+	return nil
+}
+
+// authenticate validates the bearer token of the call.
+func (i *Interceptor) authenticate(ctx context.Context) (Identity, error) {
+	accessToken, err := postService.GetBearerTokenFromGrpc(ctx)
+	if err != nil {
+		return Identity{}, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	userID, err := postService.ValidateJWT(accessToken, i.tokenSecret)
+	if err != nil {
+		return Identity{}, status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+
+	role, err := i.roleOf(ctx, userID)
+	if errors.Is(err, ErrUnknownUser) {
+		return Identity{}, status.Error(codes.Unauthenticated, "account no longer exists")
+	}
+	if err != nil {
+		return Identity{}, status.Error(codes.Internal, "can't check caller role")
+	}
+
+	return Identity{UserID: userID, Role: role}, nil
+}
+
+func requiredRole(policy Policy) Role {
+	if policy == Moderator {
+		return RoleModerator
+	}
+	return RoleAdmin
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -5,6 +5,7 @@
 package database
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,6 +19,23 @@ type Comment struct {
 	CommentText string
 }
 
+type DataExport struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Status      string
+	Error       string
+	SizeBytes   int64
+	CreatedAt   time.Time
+	StartedAt   sql.NullTime
+	CompletedAt sql.NullTime
+	ExpiresAt   sql.NullTime
+}
+
+type DataExportArchive struct {
+	ExportID uuid.UUID
+	Archive  []byte
+}
+
 type DeviceToken struct {
 	ID          uuid.UUID
 	UserID      uuid.UUID
@@ -25,6 +43,36 @@ type DeviceToken struct {
 	DeviceType  string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	LastSeenAt  time.Time
+}
+
+type EmailChangeRequest struct {
+	UserID    uuid.UUID
+	NewEmail  string
+	CodeHash  string
+	Attempts  int32
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type EmailVerification struct {
+	UserID    uuid.UUID
+	CodeHash  string
+	Attempts  int32
+	ExpiresAt time.Time
+	SentAt    time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type FollowRequest struct {
+	RequesterID uuid.UUID
+	TargetID    uuid.UUID
+	CreatedAt   time.Time
 }
 
 type Message struct {
@@ -35,6 +83,23 @@ type Message struct {
 	Content    string
 }
 
+type PasswordHistory struct {
+	UserID       uuid.UUID
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+type PasswordResetToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	TokenHash sql.NullString
+	Attempts  int32
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
 type Post struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
@@ -46,11 +111,36 @@ type Post struct {
 	LikedBy   []string
 }
 
+type PurgeFailure struct {
+	UserID    uuid.UUID
+	Attempts  int32
+	LastError string
+	RetryAt   time.Time
+}
+
+type RateLimitEvent struct {
+	Subject   string
+	CreatedAt time.Time
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
 type RefreshToken struct {
 	Token      string
 	UserID     uuid.UUID
 	ExpiryTime time.Time
 	CreatedAt  time.Time
+	ID         uuid.UUID
+	TokenHash  string
+	UserAgent  string
+	Ip         string
+	LastUsedAt sql.NullTime
 }
 
 type Report struct {
@@ -60,16 +150,76 @@ type Report struct {
 	Reason     string
 }
 
+type TotpSecret struct {
+	UserID       uuid.UUID
+	Secret       []byte
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
 type User struct {
-	ID               uuid.UUID
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
-	Email            string
-	Password         string
-	Username         string
-	Subscribers      []uuid.UUID
-	SubscribedTo     []uuid.UUID
-	IsPremium        bool
-	VerificationCode int32
-	IsVerified       bool
+	ID                     uuid.UUID
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+	Email                  string
+	Password               string
+	Username               string
+	IsPremium              bool
+	VerificationCode       int32
+	VerificationExpireTime time.Time
+	IsVerified             bool
+	IsPrivate              bool
+	DisplayName            string
+	Bio                    string
+	AvatarUrl              string
+	Links                  []string
+	Location               string
+	Birthday               sql.NullTime
+	Role                   string
+	VerificationCodeHash   string
+	VerificationAttempts   int32
+	VerificationSentAt     sql.NullTime
+	VerifiedAt             sql.NullTime
+	UsernameChangedAt      sql.NullTime
+	DeletedAt              sql.NullTime
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	Kind      string
+	CreatedAt time.Time
+}
+
+type UsernameHistory struct {
+	Username      string
+	UserID        uuid.UUID
+	ReleasedAt    time.Time
+	RedirectUntil time.Time
+}
+
+type WebauthnChallenge struct {
+	ID        uuid.UUID
+	UserID    uuid.NullUUID
+	Ceremony  string
+	Challenge []byte
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type WebauthnCredential struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	CredentialID   []byte
+	Name           string
+	PublicKey      []byte
+	Algorithm      int32
+	SignCount      int64
+	Aaguid         uuid.UUID
+	Transports     []string
+	BackupEligible bool
+	BackedUp       bool
+	CreatedAt      time.Time
+	LastUsedAt     sql.NullTime
 }
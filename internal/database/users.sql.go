@@ -7,6 +7,8 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
@@ -30,18 +32,19 @@ func (q *Queries) ChangePassword(ctx context.Context, arg ChangePasswordParams)
 
 const changeUsername = `-- name: ChangeUsername :one
 UPDATE users
-SET username = $2, updated_at = NOW()
+SET username = $2, username_changed_at = $3, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, password, username, subscribers, subscribed_to, is_premium, verification_code, is_verified
+RETURNING id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at
 `
 
 type ChangeUsernameParams struct {
-	ID       uuid.UUID
-	Username string
+	ID                uuid.UUID
+	Username          string
+	UsernameChangedAt sql.NullTime
 }
 
 func (q *Queries) ChangeUsername(ctx context.Context, arg ChangeUsernameParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, changeUsername, arg.ID, arg.Username)
+	row := q.db.QueryRowContext(ctx, changeUsername, arg.ID, arg.Username, arg.UsernameChangedAt)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -50,40 +53,108 @@ func (q *Queries) ChangeUsername(ctx context.Context, arg ChangeUsernameParams)
 		&i.Email,
 		&i.Password,
 		&i.Username,
-		pq.Array(&i.Subscribers),
-		pq.Array(&i.SubscribedTo),
 		&i.IsPremium,
 		&i.VerificationCode,
+		&i.VerificationExpireTime,
 		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const deleteAllUsers = `-- name: DeleteAllUsers :exec
-DELETE FROM users
+const clearVerificationCode = `-- name: ClearVerificationCode :exec
+UPDATE users
+SET verification_code = 0,
+    verification_code_hash = '',
+    verification_attempts = 0
+WHERE id = $1
 `
 
-func (q *Queries) DeleteAllUsers(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, deleteAllUsers)
+func (q *Queries) ClearVerificationCode(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, clearVerificationCode, id)
 	return err
 }
 
-const deleteUser = `-- name: DeleteUser :exec
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE deleted_at IS NULL
+  AND ($1::boolean IS NULL OR is_premium = $1)
+  AND ($2::boolean IS NULL OR is_verified = $2)
+  AND ($3::timestamp IS NULL OR created_at > $3)
+  AND ($4::timestamp IS NULL OR created_at < $4)
+`
+
+type CountUsersParams struct {
+	IsPremium     sql.NullBool
+	IsVerified    sql.NullBool
+	CreatedAfter  sql.NullTime
+	CreatedBefore sql.NullTime
+}
+
+func (q *Queries) CountUsers(ctx context.Context, arg CountUsersParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers,
+		arg.IsPremium,
+		arg.IsVerified,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteAllUsers = `-- name: DeleteAllUsers :exec
 DELETE FROM users
-WHERE id = $1
 `
 
-func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteUser, id)
+func (q *Queries) DeleteAllUsers(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteAllUsers)
 	return err
 }
 
-const getAllUsers = `-- name: GetAllUsers :many
-SELECT id, created_at, updated_at, email, password, username, subscribers, subscribed_to, is_premium, verification_code, is_verified FROM users
+const exportUsersBatch = `-- name: ExportUsersBatch :many
+SELECT id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at FROM users
+WHERE deleted_at IS NULL
+  AND ($1::boolean IS NULL OR is_premium = $1)
+  AND ($2::boolean IS NULL OR is_verified = $2)
+  AND ($3::timestamp IS NULL OR created_at > $3)
+  AND ($4::timestamp IS NULL OR created_at < $4)
+  AND ($5::uuid IS NULL OR id > $5)
+ORDER BY id
+LIMIT $6
 `
 
-func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.QueryContext(ctx, getAllUsers)
+type ExportUsersBatchParams struct {
+	IsPremium     sql.NullBool
+	IsVerified    sql.NullBool
+	CreatedAfter  sql.NullTime
+	CreatedBefore sql.NullTime
+	AfterID       uuid.NullUUID
+	BatchSize     int32
+}
+
+func (q *Queries) ExportUsersBatch(ctx context.Context, arg ExportUsersBatchParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, exportUsersBatch,
+		arg.IsPremium,
+		arg.IsVerified,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.AfterID,
+		arg.BatchSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -98,11 +169,24 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 			&i.Email,
 			&i.Password,
 			&i.Username,
-			pq.Array(&i.Subscribers),
-			pq.Array(&i.SubscribedTo),
 			&i.IsPremium,
 			&i.VerificationCode,
+			&i.VerificationExpireTime,
 			&i.IsVerified,
+			&i.IsPrivate,
+			&i.DisplayName,
+			&i.Bio,
+			&i.AvatarUrl,
+			pq.Array(&i.Links),
+			&i.Location,
+			&i.Birthday,
+			&i.Role,
+			&i.VerificationCodeHash,
+			&i.VerificationAttempts,
+			&i.VerificationSentAt,
+			&i.VerifiedAt,
+			&i.UsernameChangedAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -117,9 +201,53 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const getDeletedUserByEmailOrUsername = `-- name: GetDeletedUserByEmailOrUsername :one
+SELECT id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at FROM users
+WHERE (email = $1 OR LOWER(username) = LOWER($2))
+  AND deleted_at IS NOT NULL
+`
+
+type GetDeletedUserByEmailOrUsernameParams struct {
+	Email    string
+	Username string
+}
+
+func (q *Queries) GetDeletedUserByEmailOrUsername(ctx context.Context, arg GetDeletedUserByEmailOrUsernameParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedUserByEmailOrUsername, arg.Email, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.Username,
+		&i.IsPremium,
+		&i.VerificationCode,
+		&i.VerificationExpireTime,
+		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const getUserByEmailOrUsername = `-- name: GetUserByEmailOrUsername :one
-SELECT id, created_at, updated_at, email, password, username, subscribers, subscribed_to, is_premium, verification_code, is_verified FROM users
-WHERE email = $1 OR username = $2
+SELECT id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at FROM users
+WHERE (email = $1 OR LOWER(username) = LOWER($2))
+  AND deleted_at IS NULL
 `
 
 type GetUserByEmailOrUsernameParams struct {
@@ -137,18 +265,31 @@ func (q *Queries) GetUserByEmailOrUsername(ctx context.Context, arg GetUserByEma
 		&i.Email,
 		&i.Password,
 		&i.Username,
-		pq.Array(&i.Subscribers),
-		pq.Array(&i.SubscribedTo),
 		&i.IsPremium,
 		&i.VerificationCode,
+		&i.VerificationExpireTime,
 		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, created_at, updated_at, email, password, username, subscribers, subscribed_to, is_premium, verification_code, is_verified FROM users
-WHERE id = $1
+SELECT id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at FROM users
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -161,96 +302,654 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.Password,
 		&i.Username,
-		pq.Array(&i.Subscribers),
-		pq.Array(&i.SubscribedTo),
 		&i.IsPremium,
 		&i.VerificationCode,
+		&i.VerificationExpireTime,
 		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const resetPassword = `-- name: ResetPassword :exec
+const getUserRole = `-- name: GetUserRole :one
+SELECT role FROM users
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserRole(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRowContext(ctx, getUserRole, id)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
+const grantAdminRole = `-- name: GrantAdminRole :execrows
 UPDATE users
-SET password = $2, updated_at = NOW()
+SET role = 'admin', updated_at = NOW()
+WHERE id = $1 AND role <> 'admin' AND deleted_at IS NULL
+`
+
+func (q *Queries) GrantAdminRole(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, grantAdminRole, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const isUserVerified = `-- name: IsUserVerified :one
+SELECT is_verified FROM users
 WHERE id = $1
 `
 
-type ResetPasswordParams struct {
-	ID       uuid.UUID
-	Password string
+func (q *Queries) IsUserVerified(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isUserVerified, id)
+	var is_verified bool
+	err := row.Scan(&is_verified)
+	return is_verified, err
 }
 
-func (q *Queries) ResetPassword(ctx context.Context, arg ResetPasswordParams) error {
-	_, err := q.db.ExecContext(ctx, resetPassword, arg.ID, arg.Password)
-	return err
+const listUsersNewestFirst = `-- name: ListUsersNewestFirst :many
+SELECT id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at FROM users
+WHERE deleted_at IS NULL
+  AND ($1::boolean IS NULL OR is_premium = $1)
+  AND ($2::boolean IS NULL OR is_verified = $2)
+  AND ($3::timestamp IS NULL OR created_at > $3)
+  AND ($4::timestamp IS NULL OR created_at < $4)
+  AND ($5::timestamp IS NULL
+       OR (created_at, id) < ($5, $6::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $7
+`
+
+type ListUsersNewestFirstParams struct {
+	IsPremium       sql.NullBool
+	IsVerified      sql.NullBool
+	CreatedAfter    sql.NullTime
+	CreatedBefore   sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) ListUsersNewestFirst(ctx context.Context, arg ListUsersNewestFirstParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersNewestFirst,
+		arg.IsPremium,
+		arg.IsVerified,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.Password,
+			&i.Username,
+			&i.IsPremium,
+			&i.VerificationCode,
+			&i.VerificationExpireTime,
+			&i.IsVerified,
+			&i.IsPrivate,
+			&i.DisplayName,
+			&i.Bio,
+			&i.AvatarUrl,
+			pq.Array(&i.Links),
+			&i.Location,
+			&i.Birthday,
+			&i.Role,
+			&i.VerificationCodeHash,
+			&i.VerificationAttempts,
+			&i.VerificationSentAt,
+			&i.VerifiedAt,
+			&i.UsernameChangedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersOldestFirst = `-- name: ListUsersOldestFirst :many
+SELECT id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at FROM users
+WHERE deleted_at IS NULL
+  AND ($1::boolean IS NULL OR is_premium = $1)
+  AND ($2::boolean IS NULL OR is_verified = $2)
+  AND ($3::timestamp IS NULL OR created_at > $3)
+  AND ($4::timestamp IS NULL OR created_at < $4)
+  AND ($5::timestamp IS NULL
+       OR (created_at, id) > ($5, $6::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $7
+`
+
+type ListUsersOldestFirstParams struct {
+	IsPremium       sql.NullBool
+	IsVerified      sql.NullBool
+	CreatedAfter    sql.NullTime
+	CreatedBefore   sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) ListUsersOldestFirst(ctx context.Context, arg ListUsersOldestFirstParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersOldestFirst,
+		arg.IsPremium,
+		arg.IsVerified,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.Password,
+			&i.Username,
+			&i.IsPremium,
+			&i.VerificationCode,
+			&i.VerificationExpireTime,
+			&i.IsVerified,
+			&i.IsPrivate,
+			&i.DisplayName,
+			&i.Bio,
+			&i.AvatarUrl,
+			pq.Array(&i.Links),
+			&i.Location,
+			&i.Birthday,
+			&i.Role,
+			&i.VerificationCodeHash,
+			&i.VerificationAttempts,
+			&i.VerificationSentAt,
+			&i.VerifiedAt,
+			&i.UsernameChangedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const sendResetVerificationCode = `-- name: SendResetVerificationCode :exec
+const markEmailVerified = `-- name: MarkEmailVerified :one
 UPDATE users
-SET verification_code = $2
+SET is_verified = TRUE, verified_at = $2, updated_at = NOW()
 WHERE id = $1
+RETURNING id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at
 `
 
-type SendResetVerificationCodeParams struct {
-	ID               uuid.UUID
-	VerificationCode int32
+type MarkEmailVerifiedParams struct {
+	ID         uuid.UUID
+	VerifiedAt sql.NullTime
 }
 
-func (q *Queries) SendResetVerificationCode(ctx context.Context, arg SendResetVerificationCodeParams) error {
-	_, err := q.db.ExecContext(ctx, sendResetVerificationCode, arg.ID, arg.VerificationCode)
-	return err
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, markEmailVerified, arg.ID, arg.VerifiedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.Username,
+		&i.IsPremium,
+		&i.VerificationCode,
+		&i.VerificationExpireTime,
+		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
+	)
+	return i, err
 }
 
-const subscribeUser = `-- name: SubscribeUser :exec
-WITH subscribed_update AS (
-    UPDATE users
-    SET subscribed_to = array_append(subscribed_to, $1)
-    WHERE id = $2
-)
+const recordVerificationAttempt = `-- name: RecordVerificationAttempt :one
+UPDATE users
+SET verification_attempts = verification_attempts + 1
+WHERE id = $1
+RETURNING verification_attempts
+`
+
+func (q *Queries) RecordVerificationAttempt(ctx context.Context, id uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordVerificationAttempt, id)
+	var verification_attempts int32
+	err := row.Scan(&verification_attempts)
+	return verification_attempts, err
+}
+
+const restoreUser = `-- name: RestoreUser :one
 UPDATE users
-SET subscribers = array_append(subscribers, $2)
-WHERE users.id = $1
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at > $2::timestamp
+RETURNING id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at
 `
 
-type SubscribeUserParams struct {
-	ID          uuid.UUID
-	ArrayAppend interface{}
+type RestoreUserParams struct {
+	ID           uuid.UUID
+	DeletedAfter time.Time
 }
 
-func (q *Queries) SubscribeUser(ctx context.Context, arg SubscribeUserParams) error {
-	_, err := q.db.ExecContext(ctx, subscribeUser, arg.ID, arg.ArrayAppend)
-	return err
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, arg.ID, arg.DeletedAfter)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.Username,
+		&i.IsPremium,
+		&i.VerificationCode,
+		&i.VerificationExpireTime,
+		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
+	)
+	return i, err
 }
 
-const unsubscribeUser = `-- name: UnsubscribeUser :exec
-WITH unsubscribed_update AS (
-    UPDATE users
-    SET subscribed_to = array_remove(subscribed_to, $1)
-    WHERE id = $2
-)
+const revokeUserRole = `-- name: RevokeUserRole :one
 UPDATE users
-SET subscribers = array_remove(subscribers, $2)
-WHERE users.id = $1
+SET role = 'user', updated_at = NOW()
+WHERE id = $1 AND role = $2
+RETURNING id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at
 `
 
-type UnsubscribeUserParams struct {
-	ID          uuid.UUID
-	ArrayRemove interface{}
+type RevokeUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
 }
 
-func (q *Queries) UnsubscribeUser(ctx context.Context, arg UnsubscribeUserParams) error {
-	_, err := q.db.ExecContext(ctx, unsubscribeUser, arg.ID, arg.ArrayRemove)
-	return err
+func (q *Queries) RevokeUserRole(ctx context.Context, arg RevokeUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, revokeUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.Username,
+		&i.IsPremium,
+		&i.VerificationCode,
+		&i.VerificationExpireTime,
+		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at FROM users
+WHERE deleted_at IS NULL
+  AND (username ILIKE $1::text
+       OR username % $2::text
+       OR ($3::boolean AND email = $2::text))
+ORDER BY
+   ($3::boolean AND email = $2::text) DESC,
+   (username ILIKE $1::text) DESC,
+   similarity(username, $2::text) DESC,
+   username
+LIMIT $5 OFFSET $4
+`
+
+type SearchUsersParams struct {
+	Prefix     string
+	Query      string
+	MatchEmail bool
+	PageOffset int32
+	PageSize   int32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.Prefix,
+		arg.Query,
+		arg.MatchEmail,
+		arg.PageOffset,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.Password,
+			&i.Username,
+			&i.IsPremium,
+			&i.VerificationCode,
+			&i.VerificationExpireTime,
+			&i.IsVerified,
+			&i.IsPrivate,
+			&i.DisplayName,
+			&i.Bio,
+			&i.AvatarUrl,
+			pq.Array(&i.Links),
+			&i.Location,
+			&i.Birthday,
+			&i.Role,
+			&i.VerificationCodeHash,
+			&i.VerificationAttempts,
+			&i.VerificationSentAt,
+			&i.VerifiedAt,
+			&i.UsernameChangedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const verifyVerificationCode = `-- name: VerifyVerificationCode :exec
-UPDATE users 
-SET verification_code = 0
+const setAccountPrivacy = `-- name: SetAccountPrivacy :one
+UPDATE users
+SET is_private = $2, updated_at = NOW()
 WHERE id = $1
+RETURNING id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at
+`
+
+type SetAccountPrivacyParams struct {
+	ID        uuid.UUID
+	IsPrivate bool
+}
+
+func (q *Queries) SetAccountPrivacy(ctx context.Context, arg SetAccountPrivacyParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setAccountPrivacy, arg.ID, arg.IsPrivate)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.Username,
+		&i.IsPremium,
+		&i.VerificationCode,
+		&i.VerificationExpireTime,
+		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at
+`
+
+type SetUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.Role, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.Username,
+		&i.IsPremium,
+		&i.VerificationCode,
+		&i.VerificationExpireTime,
+		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :exec
+UPDATE users
+SET deleted_at = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
 `
 
-func (q *Queries) VerifyVerificationCode(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, verifyVerificationCode, id)
+type SoftDeleteUserParams struct {
+	ID        uuid.UUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, arg SoftDeleteUserParams) error {
+	_, err := q.db.ExecContext(ctx, softDeleteUser, arg.ID, arg.DeletedAt)
 	return err
 }
+
+const storeVerificationCode = `-- name: StoreVerificationCode :execrows
+UPDATE users
+SET verification_code = 0,
+    verification_code_hash = $1,
+    verification_expire_time = $2,
+    verification_attempts = 0,
+    verification_sent_at = $3
+WHERE id = $4
+  AND (verification_sent_at IS NULL OR verification_sent_at <= $5)
+`
+
+type StoreVerificationCodeParams struct {
+	CodeHash   string
+	ExpiresAt  time.Time
+	SentAt     sql.NullTime
+	ID         uuid.UUID
+	SentBefore sql.NullTime
+}
+
+func (q *Queries) StoreVerificationCode(ctx context.Context, arg StoreVerificationCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, storeVerificationCode,
+		arg.CodeHash,
+		arg.ExpiresAt,
+		arg.SentAt,
+		arg.ID,
+		arg.SentBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateProfile = `-- name: UpdateProfile :one
+UPDATE users
+SET display_name = CASE WHEN $1::boolean THEN $2 ELSE display_name END,
+    bio = CASE WHEN $3::boolean THEN $4 ELSE bio END,
+    avatar_url = CASE WHEN $5::boolean THEN $6 ELSE avatar_url END,
+    links = CASE WHEN $7::boolean THEN $8::text[] ELSE links END,
+    location = CASE WHEN $9::boolean THEN $10 ELSE location END,
+    birthday = CASE WHEN $11::boolean THEN $12::date ELSE birthday END,
+    updated_at = NOW()
+WHERE id = $13
+RETURNING id, created_at, updated_at, email, password, username, is_premium, verification_code, verification_expire_time, is_verified, is_private, display_name, bio, avatar_url, links, location, birthday, role, verification_code_hash, verification_attempts, verification_sent_at, verified_at, username_changed_at, deleted_at
+`
+
+type UpdateProfileParams struct {
+	SetDisplayName bool
+	DisplayName    string
+	SetBio         bool
+	Bio            string
+	SetAvatarUrl   bool
+	AvatarUrl      string
+	SetLinks       bool
+	Links          []string
+	SetLocation    bool
+	Location       string
+	SetBirthday    bool
+	Birthday       sql.NullTime
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateProfile(ctx context.Context, arg UpdateProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateProfile,
+		arg.SetDisplayName,
+		arg.DisplayName,
+		arg.SetBio,
+		arg.Bio,
+		arg.SetAvatarUrl,
+		arg.AvatarUrl,
+		arg.SetLinks,
+		pq.Array(arg.Links),
+		arg.SetLocation,
+		arg.Location,
+		arg.SetBirthday,
+		arg.Birthday,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Password,
+		&i.Username,
+		&i.IsPremium,
+		&i.VerificationCode,
+		&i.VerificationExpireTime,
+		&i.IsVerified,
+		&i.IsPrivate,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		pq.Array(&i.Links),
+		&i.Location,
+		&i.Birthday,
+		&i.Role,
+		&i.VerificationCodeHash,
+		&i.VerificationAttempts,
+		&i.VerificationSentAt,
+		&i.VerifiedAt,
+		&i.UsernameChangedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,323 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+)
+
+// MaxArchiveSize is the largest archive built. Archives are held in memory while they
+// are built and stored as one value, so the export of an account with more data fails
+// with ErrArchiveTooLarge rather than exhausting the worker.
+const MaxArchiveSize = 256 << 20
+
+// ErrArchiveTooLarge is returned by BuildArchive when the archive would go over
+// MaxArchiveSize.
+var ErrArchiveTooLarge = errors.New("archive is larger than 256 MiB")
+
+// file is one JSON file of an archive and how to read its contents.
+type file struct {
+	name string
+	load func(ctx context.Context, db *database.Queries, userID uuid.UUID) (any, error)
+}
+
+// files are the contents of every archive, in order. Secrets such as password hashes,
+// verification codes, refresh tokens, TOTP secrets and recovery codes are left out.
+var files = []file{
+	{name: "profile.json", load: loadProfile},
+	{name: "following.json", load: records((*database.Queries).ExportUserFollowing, newFollowRecord)},
+	{name: "followers.json", load: records((*database.Queries).ExportUserFollowers, newFollowRecord)},
+	{name: "follow_requests.json", load: records((*database.Queries).ExportUserFollowRequests, newFollowRequestRecord)},
+	{name: "blocks.json", load: records((*database.Queries).ExportUserBlocks, newBlockRecord)},
+	{name: "username_history.json", load: records((*database.Queries).ExportUserUsernameHistory, newUsernameRecord)},
+	{name: "device_tokens.json", load: records((*database.Queries).ExportUserDeviceTokens, newDeviceTokenRecord)},
+	{name: "sessions.json", load: records((*database.Queries).ExportUserRefreshTokens, newSessionRecord)},
+	{name: "passkeys.json", load: records((*database.Queries).ListWebAuthnCredentials, newPasskeyRecord)},
+	{name: "posts.json", load: records((*database.Queries).ExportUserPosts, newPostRecord)},
+	{name: "comments.json", load: records((*database.Queries).ExportUserComments, newCommentRecord)},
+	{name: "messages.json", load: records((*database.Queries).ExportUserMessages, newMessageRecord)},
+	{name: "reports.json", load: records((*database.Queries).ExportUserReports, newReportRecord)},
+}
+
+// BuildArchive returns a zip of JSON files with everything db holds about userID, or
+// ErrArchiveTooLarge if it would go over MaxArchiveSize.
+func BuildArchive(ctx context.Context, db *database.Queries, userID uuid.UUID) ([]byte, error) {
+	buf := limitedBuffer{limit: MaxArchiveSize}
+	zw := zip.NewWriter(&buf)
+
+	for _, f := range files {
+		contents, err := f.load(ctx, db, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(contents); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// limitedBuffer is a bytes.Buffer that refuses to grow past limit bytes.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, ErrArchiveTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// records adapts a query listing rows of a user into a file loader, converting every
+// row with convert. An empty list is written as [] rather than null.
+func records[T, R any](
+	query func(*database.Queries, context.Context, uuid.UUID) ([]T, error),
+	convert func(T) R,
+) func(context.Context, *database.Queries, uuid.UUID) (any, error) {
+	return func(ctx context.Context, db *database.Queries, userID uuid.UUID) (any, error) {
+		rows, err := query(db, ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make([]R, 0, len(rows))
+		for _, row := range rows {
+			out = append(out, convert(row))
+		}
+		return out, nil
+	}
+}
+
+type profileRecord struct {
+	ID                uuid.UUID  `json:"id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	Email             string     `json:"email"`
+	Username          string     `json:"username"`
+	DisplayName       string     `json:"display_name"`
+	Bio               string     `json:"bio"`
+	AvatarURL         string     `json:"avatar_url"`
+	Links             []string   `json:"links"`
+	Location          string     `json:"location"`
+	Birthday          *time.Time `json:"birthday"`
+	Role              string     `json:"role"`
+	IsPremium         bool       `json:"is_premium"`
+	IsPrivate         bool       `json:"is_private"`
+	IsVerified        bool       `json:"is_verified"`
+	VerifiedAt        *time.Time `json:"verified_at"`
+	UsernameChangedAt *time.Time `json:"username_changed_at"`
+}
+
+func loadProfile(ctx context.Context, db *database.Queries, userID uuid.UUID) (any, error) {
+	user, err := db.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return profileRecord{
+		ID:                user.ID,
+		CreatedAt:         user.CreatedAt,
+		UpdatedAt:         user.UpdatedAt,
+		Email:             user.Email,
+		Username:          user.Username,
+		DisplayName:       user.DisplayName,
+		Bio:               user.Bio,
+		AvatarURL:         user.AvatarUrl,
+		Links:             user.Links,
+		Location:          user.Location,
+		Birthday:          nullTime(user.Birthday),
+		Role:              user.Role,
+		IsPremium:         user.IsPremium,
+		IsPrivate:         user.IsPrivate,
+		IsVerified:        user.IsVerified,
+		VerifiedAt:        nullTime(user.VerifiedAt),
+		UsernameChangedAt: nullTime(user.UsernameChangedAt),
+	}, nil
+}
+
+type followRecord struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newFollowRecord(f database.Follow) followRecord {
+	return followRecord{FollowerID: f.FollowerID, FolloweeID: f.FolloweeID, CreatedAt: f.CreatedAt}
+}
+
+type followRequestRecord struct {
+	RequesterID uuid.UUID `json:"requester_id"`
+	TargetID    uuid.UUID `json:"target_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newFollowRequestRecord(r database.FollowRequest) followRequestRecord {
+	return followRequestRecord{RequesterID: r.RequesterID, TargetID: r.TargetID, CreatedAt: r.CreatedAt}
+}
+
+type blockRecord struct {
+	BlockedID uuid.UUID `json:"blocked_id"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newBlockRecord(b database.UserBlock) blockRecord {
+	return blockRecord{BlockedID: b.BlockedID, Kind: b.Kind, CreatedAt: b.CreatedAt}
+}
+
+type usernameRecord struct {
+	Username   string    `json:"username"`
+	ReleasedAt time.Time `json:"released_at"`
+}
+
+func newUsernameRecord(h database.UsernameHistory) usernameRecord {
+	return usernameRecord{Username: h.Username, ReleasedAt: h.ReleasedAt}
+}
+
+type deviceTokenRecord struct {
+	ID          uuid.UUID `json:"id"`
+	DeviceToken string    `json:"device_token"`
+	DeviceType  string    `json:"device_type"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+func newDeviceTokenRecord(t database.DeviceToken) deviceTokenRecord {
+	return deviceTokenRecord{
+		ID:          t.ID,
+		DeviceToken: t.DeviceToken,
+		DeviceType:  t.DeviceType,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+		LastSeenAt:  t.LastSeenAt,
+	}
+}
+
+type sessionRecord struct {
+	ID         uuid.UUID  `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+func newSessionRecord(t database.ExportUserRefreshTokensRow) sessionRecord {
+	return sessionRecord{
+		ID:         t.ID,
+		UserAgent:  t.UserAgent,
+		IP:         t.Ip,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: nullTime(t.LastUsedAt),
+		ExpiresAt:  t.ExpiryTime,
+	}
+}
+
+type passkeyRecord struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	AAGUID     uuid.UUID  `json:"aaguid"`
+	Transports []string   `json:"transports"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func newPasskeyRecord(c database.WebauthnCredential) passkeyRecord {
+	return passkeyRecord{
+		ID:         c.ID,
+		Name:       c.Name,
+		AAGUID:     c.Aaguid,
+		Transports: c.Transports,
+		CreatedAt:  c.CreatedAt,
+		LastUsedAt: nullTime(c.LastUsedAt),
+	}
+}
+
+type postRecord struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	Likes     int32     `json:"likes"`
+	Views     int32     `json:"views"`
+}
+
+func newPostRecord(p database.Post) postRecord {
+	return postRecord{
+		ID:        p.ID,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		Body:      p.Body,
+		Likes:     p.Likes,
+		Views:     p.Views,
+	}
+}
+
+type commentRecord struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	PostID      uuid.UUID `json:"post_id"`
+	CommentText string    `json:"comment_text"`
+}
+
+func newCommentRecord(c database.Comment) commentRecord {
+	return commentRecord{ID: c.ID, CreatedAt: c.CreatedAt, PostID: c.PostID, CommentText: c.CommentText}
+}
+
+type messageRecord struct {
+	ID         uuid.UUID `json:"id"`
+	SentAt     time.Time `json:"sent_at"`
+	SenderID   uuid.UUID `json:"sender_id"`
+	ReceiverID uuid.UUID `json:"receiver_id"`
+	Content    string    `json:"content"`
+}
+
+func newMessageRecord(m database.Message) messageRecord {
+	return messageRecord{
+		ID:         m.ID,
+		SentAt:     m.SentAt,
+		SenderID:   m.SenderID,
+		ReceiverID: m.ReceiverID,
+		Content:    m.Content,
+	}
+}
+
+type reportRecord struct {
+	ID         uuid.UUID `json:"id"`
+	ReportedAt time.Time `json:"reported_at"`
+	Reason     string    `json:"reason"`
+}
+
+func newReportRecord(r database.Report) reportRecord {
+	return reportRecord{ID: r.ID, ReportedAt: r.ReportedAt, Reason: r.Reason}
+}
+
+// nullTime returns nil for a NULL time, which JSON encodes as null.
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
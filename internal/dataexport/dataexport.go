@@ -0,0 +1,152 @@
+// Package dataexport builds the archives users download to get a copy of everything
+// the service holds about them. Archives are built in the background by a Worker, so
+// a large account never holds up the RPC that asked for it.
+package dataexport
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/imhasandl/user-service/internal/database"
+)
+
+// Statuses of an export, as stored in data_exports.status.
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+const (
+	// ArchiveTTL is how long a built archive can be downloaded before it is deleted.
+	ArchiveTTL = 7 * 24 * time.Hour
+	// RequestCooldown is how long a user has to wait before asking for a new export
+	// once one was built.
+	RequestCooldown = 24 * time.Hour
+
+	// staleAfter is how long an export may stay running before another worker takes it
+	// over, assuming its builder died.
+	staleAfter = time.Hour
+)
+
+// Worker builds pending exports and deletes expired ones.
+type Worker struct {
+	dbConn *sql.DB
+	db     *database.Queries
+	wake   chan struct{}
+}
+
+// NewWorker creates a worker for the exports in db.
+func NewWorker(dbConn *sql.DB, db *database.Queries) *Worker {
+	return &Worker{
+		dbConn: dbConn,
+		db:     db,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the worker up to build a new export without waiting for its next run.
+func (w *Worker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run builds pending exports right away, then every interval or when notified, until
+// ctx is done.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.buildPending(ctx)
+
+		deleted, err := w.db.DeleteExpiredDataExports(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("can't delete expired data exports: %v", err)
+		}
+		if deleted > 0 {
+			log.Printf("deleted %d expired data exports", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// buildPending builds exports one by one until none is left waiting.
+func (w *Worker) buildPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now().UTC()
+		claimDataExportParams := database.ClaimDataExportParams{
+			StartedAt:   sql.NullTime{Time: now, Valid: true},
+			StaleBefore: now.Add(-staleAfter),
+		}
+		export, err := w.db.ClaimDataExport(ctx, claimDataExportParams)
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			log.Printf("can't claim data export: %v", err)
+			return
+		}
+
+		if err := w.build(ctx, export); err != nil {
+			log.Printf("can't build data export %s: %v", export.ID, err)
+		}
+	}
+}
+
+// build builds the archive of export and marks it as ready, or as failed if the
+// archive can't be built.
+func (w *Worker) build(ctx context.Context, export database.DataExport) error {
+	archive, err := BuildArchive(ctx, w.db, export.UserID)
+	if err != nil {
+		failDataExportParams := database.FailDataExportParams{
+			ID:          export.ID,
+			Error:       err.Error(),
+			CompletedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		}
+		if failErr := w.db.FailDataExport(ctx, failDataExportParams); failErr != nil {
+			return failErr
+		}
+		return err
+	}
+
+	tx, err := w.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	q := w.db.WithTx(tx)
+
+	storeDataExportArchiveParams := database.StoreDataExportArchiveParams{
+		ExportID: export.ID,
+		Archive:  archive,
+	}
+	if err := q.StoreDataExportArchive(ctx, storeDataExportArchiveParams); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	completeDataExportParams := database.CompleteDataExportParams{
+		ID:          export.ID,
+		SizeBytes:   int64(len(archive)),
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+		ExpiresAt:   sql.NullTime{Time: now.Add(ArchiveTTL), Valid: true},
+	}
+	if err := q.CompleteDataExport(ctx, completeDataExportParams); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,131 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// DefaultLocale is used when the recipient's locale has no templates.
+const DefaultLocale = "en"
+
+// Names of the templates every locale provides.
+const (
+	TemplateVerificationCode  = "verification_code"
+	TemplateEmailVerification = "email_verification"
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailChangeCode   = "email_change_code"
+	TemplateEmailChangeNotice = "email_change_notice"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// template is one email in one locale. The text file defines a "subject" template
+// next to its body; the HTML file is the HTML body.
+type template struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates renders emails in the locale of their recipient.
+type Templates struct {
+	locales map[string]map[string]template
+}
+
+// DefaultTemplates returns the templates built into the service.
+func DefaultTemplates() (*Templates, error) {
+	root, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, err
+	}
+	return LoadTemplates(root)
+}
+
+// LoadTemplates reads templates laid out as <locale>/<name>.txt and <locale>/<name>.html.
+// DefaultLocale must have every template; other locales may leave some out.
+func LoadTemplates(fsys fs.FS) (*Templates, error) {
+	t := &Templates{locales: make(map[string]map[string]template)}
+
+	files, err := fs.Glob(fsys, "*/*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		locale, name := path.Dir(file), strings.TrimSuffix(path.Base(file), ".txt")
+
+		text, err := texttemplate.ParseFS(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("mail template %s has no subject", file)
+		}
+
+		html, err := htmltemplate.ParseFS(fsys, path.Join(locale, name+".html"))
+		if err != nil {
+			return nil, err
+		}
+
+		if t.locales[locale] == nil {
+			t.locales[locale] = make(map[string]template)
+		}
+		t.locales[locale][name] = template{text: text, html: html}
+	}
+
+	for _, name := range []string{TemplateVerificationCode, TemplateEmailVerification, TemplatePasswordReset, TemplateEmailChangeCode, TemplateEmailChangeNotice} {
+		if _, ok := t.locales[DefaultLocale][name]; !ok {
+			return nil, fmt.Errorf("mail template %s is missing for locale %s", name, DefaultLocale)
+		}
+	}
+
+	return t, nil
+}
+
+// Render fills in the template called name for a recipient in locale, falling back to
+// DefaultLocale. The returned message has no recipient yet.
+func (t *Templates) Render(locale, name string, data any) (Message, error) {
+	tmpl, ok := t.locales[locale][name]
+	if !ok {
+		tmpl, ok = t.locales[DefaultLocale][name]
+	}
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %s", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// LocaleFromAcceptLanguage picks the preferred language of an Accept-Language header,
+// e.g. "ru" for "ru-RU,ru;q=0.9,en;q=0.8". Quality values are ignored; the first
+// language is taken as the preferred one.
+func LocaleFromAcceptLanguage(header string) string {
+	first, _, _ := strings.Cut(header, ",")
+	first, _, _ = strings.Cut(first, ";")
+	first, _, _ = strings.Cut(strings.TrimSpace(first), "-")
+	if first == "" || first == "*" {
+		return DefaultLocale
+	}
+	return strings.ToLower(first)
+}
@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	templates, err := DefaultTemplates()
+	if err != nil {
+		t.Fatalf("DefaultTemplates: %v", err)
+	}
+
+	data := struct{ Code, NewEmail, Link string }{Code: "012345"}
+
+	tests := []struct {
+		name    string
+		locale  string
+		subject string
+		text    string
+	}{
+		{name: "english", locale: "en", subject: "Your verification code", text: "Your verification code is 012345."},
+		{name: "russian", locale: "ru", subject: "Ваш код подтверждения", text: "Ваш код подтверждения: 012345."},
+		{name: "unknown locale falls back to english", locale: "de", subject: "Your verification code", text: "Your verification code is 012345."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := templates.Render(tt.locale, TemplateVerificationCode, data)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			if !strings.HasPrefix(msg.Text, tt.text) {
+				t.Errorf("text = %q, want it to start with %q", msg.Text, tt.text)
+			}
+			if !strings.Contains(msg.HTML, "<strong>012345</strong>") {
+				t.Errorf("html = %q, want it to contain the code", msg.HTML)
+			}
+		})
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	templates, err := DefaultTemplates()
+	if err != nil {
+		t.Fatalf("DefaultTemplates: %v", err)
+	}
+
+	data := struct{ Code, NewEmail, Link string }{Code: "012345", NewEmail: "<b>@example.com"}
+	msg, err := templates.Render("en", TemplateEmailChangeNotice, data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if strings.Contains(msg.HTML, "<b>@") {
+		t.Errorf("html = %q, want the new email escaped", msg.HTML)
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	templates, err := DefaultTemplates()
+	if err != nil {
+		t.Fatalf("DefaultTemplates: %v", err)
+	}
+
+	if _, err := templates.Render("en", "no_such_template", nil); err == nil {
+		t.Error("Render of an unknown template succeeded")
+	}
+}
+
+func TestLocaleFromAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: DefaultLocale},
+		{header: "*", want: DefaultLocale},
+		{header: "ru", want: "ru"},
+		{header: "ru-RU,ru;q=0.9,en;q=0.8", want: "ru"},
+		{header: "EN-us", want: "en"},
+		{header: " de;q=0.7, en", want: "de"},
+	}
+	for _, tt := range tests {
+		if got := LocaleFromAcceptLanguage(tt.header); got != tt.want {
+			t.Errorf("LocaleFromAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
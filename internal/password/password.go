@@ -0,0 +1,112 @@
+// Package password decides which new passwords users may choose. A Policy is a list
+// of rules checked in order; the first rule a password breaks is reported.
+package password
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Candidate is a password a user wants to switch to.
+type Candidate struct {
+	Password string
+	// PreviousHashes are the hashes of the current password and of the ones before it,
+	// newest first.
+	PreviousHashes []string
+}
+
+// Rule checks a candidate password. The error it returns is shown to the user.
+type Rule interface {
+	Check(candidate Candidate) error
+}
+
+// RuleFunc turns a function into a Rule.
+type RuleFunc func(candidate Candidate) error
+
+// Check calls f.
+func (f RuleFunc) Check(candidate Candidate) error {
+	return f(candidate)
+}
+
+// Policy is a list of rules that every new password must pass.
+type Policy []Rule
+
+// Check returns the error of the first rule candidate breaks, or nil.
+func (p Policy) Check(candidate Candidate) error {
+	for _, rule := range p {
+		if err := rule.Check(candidate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MinLength refuses passwords shorter than n characters.
+func MinLength(n int) Rule {
+	return RuleFunc(func(candidate Candidate) error {
+		if utf8.RuneCountInString(candidate.Password) < n {
+			return fmt.Errorf("password must be at least %d characters long", n)
+		}
+		return nil
+	})
+}
+
+// ErrBreached is returned for passwords found in a breached-password list.
+var ErrBreached = errors.New("password appears in a list of breached passwords, choose another one")
+
+// Breached refuses the passwords in list. Matching ignores case, so list entries
+// should be lowercase.
+func Breached(list map[string]struct{}) Rule {
+	return RuleFunc(func(candidate Candidate) error {
+		if _, ok := list[strings.ToLower(candidate.Password)]; ok {
+			return ErrBreached
+		}
+		return nil
+	})
+}
+
+// LoadBreachedList reads a breached-password list with one password per line. Empty
+// lines and lines starting with # are skipped.
+func LoadBreachedList(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// ErrReused is returned for passwords the user had before.
+var ErrReused = errors.New("password was used before, choose a new one")
+
+// NoReuse refuses passwords matching one of the previous hashes of the user. matches
+// compares a hash with a plain password and returns nil when they match, like
+// CheckPassword of the auth service.
+func NoReuse(matches func(hash, password string) error) Rule {
+	return RuleFunc(func(candidate Candidate) error {
+		for _, hash := range candidate.PreviousHashes {
+			if matches(hash, candidate.Password) == nil {
+				return ErrReused
+			}
+		}
+		return nil
+	})
+}
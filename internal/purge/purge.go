@@ -0,0 +1,177 @@
+// Package purge removes data the service no longer keeps. DeleteUser only marks an
+// account as deleted so it can be restored; once GracePeriod has passed, the Purger
+// deletes it with everything that points at it and tells the other services about it.
+// The Purger also prunes device tokens that haven't been seen for a while.
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/rabbitmq"
+)
+
+// GracePeriod is how long a deleted account can be restored before it is purged.
+const GracePeriod = 30 * 24 * time.Hour
+
+// batchSize is how many accounts are listed at a time.
+const batchSize = 100
+
+// Purger deletes the accounts whose grace period is over and stale device tokens.
+type Purger struct {
+	dbConn   *sql.DB
+	db       *database.Queries
+	rabbitmq *rabbitmq.RabbitMQ
+
+	// deviceTokenTTL is how long a device token is kept after it was last registered.
+	deviceTokenTTL time.Duration
+}
+
+// NewPurger creates a purger publishing its events on rabbitmq and pruning device
+// tokens not seen for deviceTokenTTL.
+func NewPurger(dbConn *sql.DB, db *database.Queries, rabbitmq *rabbitmq.RabbitMQ, deviceTokenTTL time.Duration) *Purger {
+	return &Purger{
+		dbConn:         dbConn,
+		db:             db,
+		rabbitmq:       rabbitmq,
+		deviceTokenTTL: deviceTokenTTL,
+	}
+}
+
+// Run purges expired accounts and prunes stale device tokens right away and then every
+// interval, until ctx is done.
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.PurgeExpired(ctx)
+		if err != nil {
+			log.Printf("can't purge deleted accounts: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("purged %d deleted accounts", purged)
+		}
+
+		pruned, err := p.db.PruneDeviceTokens(ctx, time.Now().UTC().Add(-p.deviceTokenTTL))
+		if err != nil {
+			log.Printf("can't prune device tokens: %v", err)
+		}
+		if pruned > 0 {
+			log.Printf("pruned %d stale device tokens", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeExpired purges every account deleted more than GracePeriod ago and returns how
+// many it purged. An account it fails to purge is logged and skipped, and isn't tried
+// again until its backoff in purge_failures has passed.
+func (p *Purger) PurgeExpired(ctx context.Context) (int, error) {
+	deletedBefore := time.Now().UTC().Add(-GracePeriod)
+
+	purged := 0
+	for {
+		listUsersToPurgeParams := database.ListUsersToPurgeParams{
+			DeletedBefore: deletedBefore,
+			Now:           time.Now().UTC(),
+			BatchSize:     batchSize,
+		}
+		userIDs, err := p.db.ListUsersToPurge(ctx, listUsersToPurgeParams)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, userID := range userIDs {
+			ok, err := p.purgeUser(ctx, userID, deletedBefore)
+			if err != nil {
+				// Without the failure on record the account would be listed again
+				// right away, so give up on this run instead.
+				if err := p.recordFailure(ctx, userID, err); err != nil {
+					return purged, err
+				}
+				continue
+			}
+			if ok {
+				purged++
+			}
+		}
+
+		if len(userIDs) < batchSize {
+			return purged, nil
+		}
+	}
+}
+
+// recordFailure logs that userID couldn't be purged and backs off retrying it.
+func (p *Purger) recordFailure(ctx context.Context, userID uuid.UUID, purgeErr error) error {
+	log.Printf("can't purge deleted account %s: %v", userID, purgeErr)
+
+	recordPurgeFailureParams := database.RecordPurgeFailureParams{
+		UserID:    userID,
+		LastError: purgeErr.Error(),
+		FailedAt:  time.Now().UTC(),
+	}
+	if err := p.db.RecordPurgeFailure(ctx, recordPurgeFailureParams); err != nil {
+		return fmt.Errorf("can't record purge failure of user %s: %w", userID, err)
+	}
+	return nil
+}
+
+// purgeUser deletes the account userID unless it was restored since it was listed.
+// Comments and messages of the user go with it, and their reports are kept without
+// their author. The user.deleted event is published before the transaction commits,
+// so an account is only gone once the other services were told.
+func (p *Purger) purgeUser(ctx context.Context, userID uuid.UUID, deletedBefore time.Time) (bool, error) {
+	tx, err := p.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	q := p.db.WithTx(tx)
+
+	lockUserToPurgeParams := database.LockUserToPurgeParams{
+		ID:            userID,
+		DeletedBefore: deletedBefore,
+	}
+	if _, err := q.LockUserToPurge(ctx, lockUserToPurgeParams); errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	steps := []func(context.Context, uuid.UUID) error{
+		q.DeleteUserComments,
+		q.DeleteUserMessages,
+		q.AnonymizeUserReports,
+		q.DeleteUserRefreshTokens,
+		q.PurgeUser,
+	}
+	for _, step := range steps {
+		if err := step(ctx, userID); err != nil {
+			return false, err
+		}
+	}
+
+	err = p.rabbitmq.PublishEvent(rabbitmq.Event{
+		EventType: rabbitmq.UserDeletedRoutingKey,
+		UserID:    userID.String(),
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
@@ -1,7 +1,9 @@
 package rabbitmq
 
 import (
+	"encoding/json"
 	"log"
+	"time"
 
 	"github.com/streadway/amqp"
 )
@@ -14,6 +16,22 @@ const (
 	RoutingKey   = "message-service.notification"
 )
 
+// Routing keys of the domain events other services consume from ExchangeName.
+const (
+	UserBlockedRoutingKey              = "user.blocked"
+	UserEmailChangeRequestedRoutingKey = "user.email_change_requested"
+	UserEmailChangedRoutingKey         = "user.email_changed"
+	UserDeletedRoutingKey              = "user.deleted"
+)
+
+// Event is the envelope of every domain event the user service publishes.
+type Event struct {
+	EventType string            `json:"event_type"`
+	UserID    string            `json:"user_id"`
+	Timestamp time.Time         `json:"timestamp"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
 // RabbitMQ represents a connection to a RabbitMQ server and provides methods
 // for publishing and consuming messages.
 type RabbitMQ struct {
@@ -58,3 +76,23 @@ func (r *RabbitMQ) Close() error {
 	}
 	return nil
 }
+
+// PublishEvent publishes event on ExchangeName, using its type as the routing key.
+func (r *RabbitMQ) PublishEvent(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return r.Channel.Publish(
+		ExchangeName,    // exchange
+		event.EventType, // routing key
+		false,           // mandatory
+		false,           // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    event.Timestamp,
+			Body:         body,
+		})
+}
@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// KeySize is the length of the key of a Cipher, for AES-256.
+const KeySize = 32
+
+// errSealed is returned by Open for a value that wasn't sealed with the same key and
+// user.
+var errSealed = errors.New("totp secret can't be opened with this key")
+
+// Cipher seals TOTP secrets with AES-256-GCM, so that a leaked database doesn't give
+// away the secrets of every authenticator.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher with a KeySize bytes long key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("totp key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal returns secret encrypted for storage. The user id is authenticated along with
+// it, so a sealed secret copied to another user doesn't open.
+func (c *Cipher) Seal(userID uuid.UUID, secret []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, secret, userID[:]), nil
+}
+
+// Open returns the secret sealed by Seal for userID.
+func (c *Cipher) Open(userID uuid.UUID, sealed []byte) ([]byte, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, errSealed
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, ciphertext, userID[:])
+	if err != nil {
+		return nil, errSealed
+	}
+	return secret, nil
+}
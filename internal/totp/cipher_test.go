@@ -0,0 +1,76 @@
+package totp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func newTestCipher(t *testing.T, fill byte) *Cipher {
+	t.Helper()
+	c, err := NewCipher(bytes.Repeat([]byte{fill}, KeySize))
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	return c
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, 1)
+	userID := uuid.New()
+
+	sealed, err := c.Seal(userID, rfc6238Secret)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if bytes.Contains(sealed, rfc6238Secret) {
+		t.Error("sealed secret contains the secret in the clear")
+	}
+
+	opened, err := c.Open(userID, sealed)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !bytes.Equal(opened, rfc6238Secret) {
+		t.Errorf("Open = %q, want %q", opened, rfc6238Secret)
+	}
+}
+
+func TestCipherOpenRefuses(t *testing.T) {
+	c := newTestCipher(t, 1)
+	userID := uuid.New()
+
+	sealed, err := c.Seal(userID, rfc6238Secret)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name   string
+		cipher *Cipher
+		userID uuid.UUID
+		sealed []byte
+	}{
+		{name: "wrong user", cipher: c, userID: uuid.New(), sealed: sealed},
+		{name: "wrong key", cipher: newTestCipher(t, 2), userID: userID, sealed: sealed},
+		{name: "tampered", cipher: c, userID: userID, sealed: tampered},
+		{name: "truncated", cipher: c, userID: userID, sealed: sealed[:4]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cipher.Open(tt.userID, tt.sealed); err == nil {
+				t.Error("Open succeeded")
+			}
+		})
+	}
+}
+
+func TestNewCipherKeySize(t *testing.T) {
+	if _, err := NewCipher(make([]byte, 16)); err == nil {
+		t.Error("NewCipher accepted a 16 byte key")
+	}
+}
@@ -0,0 +1,152 @@
+// Package totp implements the time-based one-time passwords of RFC 6238 that
+// authenticator apps show, and the single-use recovery codes that stand in for them
+// when the app is lost. Secrets are sealed with a Cipher before they are stored.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parameters of the codes, the defaults of RFC 6238 that every authenticator app uses.
+const (
+	Period     = 30 * time.Second
+	Digits     = 6
+	SecretSize = 20
+)
+
+// codeModulus keeps the last Digits decimal digits of a truncated HMAC.
+var codeModulus = uint32(math.Pow10(Digits))
+
+// skew is how many time steps before and after the current one are accepted, for
+// clocks that drift.
+const skew = 1
+
+// Recovery codes.
+const (
+	RecoveryCodeCount = 10
+	// recoveryCodeBytes is the randomness of a code: 48 bits, written as ten base32
+	// characters.
+	recoveryCodeBytes = 6
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random secret for a new authenticator.
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// EncodeSecret returns secret in base32, the way users type it into an app.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// URI returns the otpauth:// URI apps read from a QR code. account names the user
+// within issuer, e.g. their email.
+func URI(issuer, account string, secret []byte) string {
+	query := url.Values{}
+	query.Set("secret", EncodeSecret(secret))
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(Digits))
+	query.Set("period", strconv.Itoa(int(Period/time.Second)))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// Step returns the time step t falls in.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code of secret for a time step, using the HOTP algorithm of
+// RFC 4226 with the step as the counter.
+func Code(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%codeModulus)
+}
+
+// IsCode reports whether s looks like a TOTP code rather than a recovery code.
+func IsCode(s string) bool {
+	if len(s) != Digits {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks code against the steps around now and returns the step it matched.
+// Callers must refuse a step that isn't after the last one they accepted, so that a
+// code works only once.
+func Validate(secret []byte, code string, now time.Time) (int64, bool) {
+	if !IsCode(code) {
+		return 0, false
+	}
+
+	current := Step(now)
+	for step := current - skew; step <= current+skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(Code(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCodes returns RecoveryCodeCount random codes, formatted as
+// "xxxxx-xxxxx" for users to write down.
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(raw))
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode returns the form of a recovery code that is hashed, so that
+// case, spaces and dashes the user typed don't matter.
+func NormalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
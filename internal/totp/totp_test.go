@@ -0,0 +1,89 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 secret of the test vectors in RFC 6238, Appendix B.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	// The RFC lists 8 digit codes; these are their last Digits digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		if got := Code(rfc6238Secret, Step(time.Unix(tt.unix, 0))); got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: Code(rfc6238Secret, current), wantStep: current, wantOK: true},
+		{name: "previous step", code: Code(rfc6238Secret, current-1), wantStep: current - 1, wantOK: true},
+		{name: "next step", code: Code(rfc6238Secret, current+1), wantStep: current + 1, wantOK: true},
+		{name: "two steps ago", code: Code(rfc6238Secret, current-2)},
+		{name: "two steps ahead", code: Code(rfc6238Secret, current+2)},
+		{name: "not a code", code: "abcdef"},
+		{name: "too short", code: "12345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfc6238Secret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate(%q) = %d, %t, want %d, %t", tt.code, step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatalf("NewRecoveryCodes: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if IsCode(code) {
+			t.Errorf("recovery code %q looks like a TOTP code", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q was returned twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	for _, typed := range []string{"abcde-fghij", "ABCDE-FGHIJ", " abcde fghij ", "abcdefghij"} {
+		if got := NormalizeRecoveryCode(typed); got != "abcdefghij" {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want abcdefghij", typed, got)
+		}
+	}
+}
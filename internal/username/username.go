@@ -0,0 +1,74 @@
+// Package username holds the rules for the handles users pick. Usernames are unique
+// regardless of case, and a released handle keeps pointing at its former owner for
+// RedirectPeriod so old links and mentions still work.
+package username
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Limits of usernames.
+const (
+	MinLength = 3
+	MaxLength = 30
+
+	// ChangeCooldown is how long a user has to wait between two username changes.
+	ChangeCooldown = 14 * 24 * time.Hour
+	// RedirectPeriod is how long a released username points at its former owner. Nobody
+	// else can take it in the meantime.
+	RedirectPeriod = 30 * 24 * time.Hour
+)
+
+// ErrReserved is returned for usernames that could be mistaken for the service itself.
+var ErrReserved = errors.New("username is reserved")
+
+// reserved usernames, lowercase. They would let users pass themselves off as staff or
+// clash with routes of the clients.
+var reserved = map[string]struct{}{
+	"about": {}, "admin": {}, "administrator": {}, "api": {}, "app": {}, "help": {},
+	"login": {}, "logout": {}, "me": {}, "mod": {}, "moderator": {}, "null": {},
+	"official": {}, "register": {}, "root": {}, "security": {}, "settings": {},
+	"signup": {}, "staff": {}, "support": {}, "system": {}, "undefined": {},
+	"user": {}, "users": {}, "www": {},
+}
+
+// Validate checks that name may be used as a username: MinLength to MaxLength ASCII
+// letters, digits, underscores and dots, not starting or ending with a dot, without
+// two dots in a row, and not reserved.
+func Validate(name string) error {
+	if len(name) < MinLength || len(name) > MaxLength {
+		return fmt.Errorf("username must be %d to %d characters long", MinLength, MaxLength)
+	}
+
+	for _, r := range name {
+		if !isAllowed(r) {
+			return errors.New("username may only contain letters, digits, underscores and dots")
+		}
+	}
+
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") || strings.Contains(name, "..") {
+		return errors.New("username can't start or end with a dot or have two dots in a row")
+	}
+
+	if _, ok := reserved[strings.ToLower(name)]; ok {
+		return ErrReserved
+	}
+
+	return nil
+}
+
+func isAllowed(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.'
+}
+
+// NextChange returns when a user who last changed their username at lastChange may
+// change it again. A zero lastChange means they never did, so they can right away.
+func NextChange(lastChange time.Time) time.Time {
+	if lastChange.IsZero() {
+		return time.Time{}
+	}
+	return lastChange.Add(ChangeCooldown)
+}
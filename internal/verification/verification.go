@@ -0,0 +1,111 @@
+// Package verification issues and checks the short numeric codes emailed to users.
+// Codes are stored as keyed hashes, expire after CodeTTL and allow MaxAttempts
+// guesses, after which the flow stays locked until the code would have expired.
+package verification
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Limits of the verification code flow.
+const (
+	CodeTTL        = 15 * time.Minute
+	MaxAttempts    = 5
+	ResendCooldown = time.Minute
+)
+
+// Errors returned by Check and CanIssue.
+var (
+	ErrNoCode          = errors.New("no verification code was requested")
+	ErrExpired         = errors.New("verification code expired")
+	ErrTooManyAttempts = errors.New("too many wrong verification codes")
+	ErrWrongCode       = errors.New("verification code is not correct")
+	ErrCooldown        = errors.New("a verification code was sent recently")
+)
+
+// State is what the database holds about the current code of a user.
+type State struct {
+	Hash      string
+	ExpiresAt time.Time
+	Attempts  int32
+	SentAt    time.Time // Zero if no code was ever sent
+}
+
+// Locked reports whether the user used up their attempts on a code that is still live.
+func (s State) Locked(now time.Time) bool {
+	return s.Hash != "" && s.Attempts >= MaxAttempts && now.Before(s.ExpiresAt)
+}
+
+// Hasher hashes codes with a server-side key, so that a leaked database doesn't give
+// away live codes: there are only a million six-digit codes to try.
+type Hasher struct {
+	key []byte
+}
+
+// NewHasher derives the hashing key from secret.
+func NewHasher(secret string) Hasher {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("user-service verification codes"))
+	return Hasher{key: mac.Sum(nil)}
+}
+
+// Hash returns the value to store for code. The user id is part of the hash so equal
+// codes of different users don't produce equal hashes.
+func (h Hasher) Hash(userID uuid.UUID, code int32) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(userID.String() + ":" + strconv.FormatInt(int64(code), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HashToken returns the value to store for a token made by NewToken.
+func (h Hasher) HashToken(token string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte("token:" + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewToken returns a random token to put in links, as an alternative to typing a code.
+func NewToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Check tells whether code is the live code of userID. attempts must already count
+// this attempt, so concurrent guesses can't get past MaxAttempts.
+func (h Hasher) Check(state State, userID uuid.UUID, code int32, attempts int32, now time.Time) error {
+	switch {
+	case state.Hash == "":
+		return ErrNoCode
+	case attempts > MaxAttempts:
+		return ErrTooManyAttempts
+	case !now.Before(state.ExpiresAt):
+		return ErrExpired
+	case !hmac.Equal([]byte(state.Hash), []byte(h.Hash(userID, code))):
+		return ErrWrongCode
+	default:
+		return nil
+	}
+}
+
+// CanIssue tells whether a new code may be sent now.
+func CanIssue(state State, now time.Time) error {
+	if state.Locked(now) {
+		return ErrTooManyAttempts
+	}
+	if !state.SentAt.IsZero() && now.Sub(state.SentAt) < ResendCooldown {
+		return ErrCooldown
+	}
+	return nil
+}
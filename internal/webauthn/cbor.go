@@ -0,0 +1,164 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// maxCBORDepth bounds how deeply arrays and maps may nest, so a crafted message
+// can't exhaust the stack.
+const maxCBORDepth = 16
+
+var errCBOR = errors.New("malformed CBOR")
+
+// decodeCBOR decodes the first CBOR item of data and returns it with the bytes that
+// follow it. It supports what authenticators send, in the definite-length encoding
+// CTAP2 requires: integers (as int64), byte and text strings, arrays ([]any), maps
+// (map[any]any), booleans and null.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (any, []byte, error) {
+	if depth > maxCBORDepth || len(data) == 0 {
+		return nil, nil, errCBOR
+	}
+
+	major := data[0] >> 5
+	if major == 7 {
+		return decodeCBORSimple(data)
+	}
+
+	arg, rest, err := decodeCBORArgument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0, 1:
+		return decodeCBORInt(major, arg, rest)
+	case 2, 3:
+		return decodeCBORString(major, arg, rest)
+	case 4:
+		return decodeCBORArray(rest, arg, depth)
+	case 5:
+		return decodeCBORMap(rest, arg, depth)
+	default:
+		// Tags aren't used by authenticators.
+		return nil, nil, errCBOR
+	}
+}
+
+// decodeCBORArgument reads the argument of the item at the start of data: its value,
+// length or number of elements.
+func decodeCBORArgument(data []byte) (uint64, []byte, error) {
+	info := data[0] & 0x1f
+	rest := data[1:]
+
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), rest, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// Indefinite lengths and reserved values.
+		return 0, nil, errCBOR
+	}
+	if len(rest) < size {
+		return 0, nil, errCBOR
+	}
+
+	var buf [8]byte
+	copy(buf[8-size:], rest[:size])
+	return binary.BigEndian.Uint64(buf[:]), rest[size:], nil
+}
+
+func decodeCBORInt(major byte, arg uint64, rest []byte) (any, []byte, error) {
+	if arg > math.MaxInt64 {
+		return nil, nil, errCBOR
+	}
+	if major == 1 {
+		return -1 - int64(arg), rest, nil
+	}
+	return int64(arg), rest, nil
+}
+
+func decodeCBORString(major byte, length uint64, rest []byte) (any, []byte, error) {
+	if length > uint64(len(rest)) {
+		return nil, nil, errCBOR
+	}
+	if major == 2 {
+		return rest[:length], rest[length:], nil
+	}
+	return string(rest[:length]), rest[length:], nil
+}
+
+func decodeCBORSimple(data []byte) (any, []byte, error) {
+	switch data[0] & 0x1f {
+	case 20:
+		return false, data[1:], nil
+	case 21:
+		return true, data[1:], nil
+	case 22:
+		return nil, data[1:], nil
+	default:
+		return nil, nil, errCBOR
+	}
+}
+
+func decodeCBORArray(data []byte, n uint64, depth int) (any, []byte, error) {
+	// Every element takes at least one byte.
+	if n > uint64(len(data)) {
+		return nil, nil, errCBOR
+	}
+
+	items := make([]any, 0, n)
+	for i := uint64(0); i < n; i++ {
+		item, rest, err := decodeCBORItem(data, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, item)
+		data = rest
+	}
+	return items, data, nil
+}
+
+func decodeCBORMap(data []byte, n uint64, depth int) (any, []byte, error) {
+	// Every key and value takes at least one byte.
+	if n > uint64(len(data))/2 {
+		return nil, nil, errCBOR
+	}
+
+	m := make(map[any]any, n)
+	for i := uint64(0); i < n; i++ {
+		key, rest, err := decodeCBORItem(data, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch key.(type) {
+		case int64, string:
+		default:
+			return nil, nil, errCBOR
+		}
+		if _, ok := m[key]; ok {
+			return nil, nil, errCBOR
+		}
+
+		value, rest, err := decodeCBORItem(rest, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		m[key] = value
+		data = rest
+	}
+	return m, data, nil
+}
@@ -0,0 +1,130 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// head encodes the initial byte and argument of a CBOR item.
+func head(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	default:
+		b := []byte{major<<5 | 27, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(b[1:], n)
+		return b
+	}
+}
+
+func cint(n int64) []byte {
+	if n < 0 {
+		return head(1, uint64(-1-n))
+	}
+	return head(0, uint64(n))
+}
+
+func cbytes(b []byte) []byte {
+	return append(head(2, uint64(len(b))), b...)
+}
+
+func ctext(s string) []byte {
+	return append(head(3, uint64(len(s))), s...)
+}
+
+// cmap encodes a map from its keys and values, in order.
+func cmap(items ...[]byte) []byte {
+	return append(head(5, uint64(len(items)/2)), bytes.Join(items, nil)...)
+}
+
+func carray(items ...[]byte) []byte {
+	return append(head(4, uint64(len(items))), bytes.Join(items, nil)...)
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	data := cmap(
+		cint(1), cint(-7),
+		ctext("fmt"), ctext("none"),
+		cint(-2), cbytes([]byte{1, 2, 3}),
+		ctext("list"), carray(cint(1000), []byte{0xf5}, []byte{0xf4}, []byte{0xf6}),
+	)
+	data = append(data, 0xaa)
+
+	got, rest, err := decodeCBOR(data)
+	if err != nil {
+		t.Fatalf("decodeCBOR: %v", err)
+	}
+	want := map[any]any{
+		int64(1):  int64(-7),
+		"fmt":     "none",
+		int64(-2): []byte{1, 2, 3},
+		"list":    []any{int64(1000), true, false, nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeCBOR = %#v, want %#v", got, want)
+	}
+	if !bytes.Equal(rest, []byte{0xaa}) {
+		t.Errorf("rest = %x, want aa", rest)
+	}
+}
+
+func TestDecodeCBORRejects(t *testing.T) {
+	nested := func(depth int) []byte {
+		data := cint(0)
+		for i := 0; i < depth; i++ {
+			data = carray(data)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "truncated argument", data: []byte{0x19, 0x01}},
+		{name: "truncated byte string", data: []byte{0x45, 'a', 'b'}},
+		{name: "truncated text string", data: []byte{0x65, 'a', 'b'}},
+		{name: "map without its last value", data: []byte{0xa2, 0x01, 0x02, 0x03}},
+		{name: "array longer than its data", data: []byte{0x9a, 0xff, 0xff, 0xff, 0xff, 0x00}},
+		{name: "map longer than its data", data: []byte{0xba, 0xff, 0xff, 0xff, 0xff, 0x00}},
+		{name: "indefinite byte string", data: []byte{0x5f, 0x41, 'a', 0xff}},
+		{name: "indefinite text string", data: []byte{0x7f, 0x61, 'a', 0xff}},
+		{name: "indefinite array", data: []byte{0x9f, 0x01, 0xff}},
+		{name: "indefinite map", data: []byte{0xbf, 0x01, 0x02, 0xff}},
+		{name: "reserved argument", data: []byte{0x1c}},
+		{name: "duplicate int keys", data: cmap(cint(1), cint(1), cint(1), cint(2))},
+		{name: "duplicate text keys", data: cmap(ctext("a"), cint(1), ctext("a"), cint(2))},
+		{name: "non-scalar key", data: cmap(carray(), cint(1))},
+		{name: "boolean key", data: cmap([]byte{0xf5}, cint(1))},
+		{name: "tag", data: []byte{0xc0, 0x60}},
+		{name: "float", data: []byte{0xf9, 0x3c, 0x00}},
+		{name: "integer over int64", data: head(0, 1<<63)},
+		{name: "negative integer under int64", data: head(1, 1<<63)},
+		{name: "too deep", data: nested(maxCBORDepth + 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(tt.data); !errors.Is(err, errCBOR) {
+				t.Errorf("decodeCBOR(%x) error = %v, want errCBOR", tt.data, err)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORDepthLimit(t *testing.T) {
+	data := cint(0)
+	for i := 0; i < maxCBORDepth; i++ {
+		data = carray(data)
+	}
+	if _, _, err := decodeCBOR(data); err != nil {
+		t.Errorf("decodeCBOR of %d nested arrays: %v", maxCBORDepth, err)
+	}
+}
@@ -0,0 +1,136 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithms of the credentials this package accepts.
+const (
+	AlgES256 int64 = -7   // ECDSA on P-256 with SHA-256
+	AlgRS256 int64 = -257 // RSASSA-PKCS1-v1_5 with SHA-256
+)
+
+// COSE key parameters, see RFC 9053.
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseEC2Curve  = -1
+	coseEC2X      = -2
+	coseEC2Y      = -3
+	coseCurveP256 = 1
+
+	coseRSAModulus  = -1
+	coseRSAExponent = -2
+)
+
+// minRSABits is the smallest RSA key accepted.
+const minRSABits = 2048
+
+// publicKey is the public key of a credential, checking signatures over a SHA-256
+// digest.
+type publicKey interface {
+	verify(digest, signature []byte) bool
+}
+
+type es256Key struct {
+	key *ecdsa.PublicKey
+}
+
+func (k es256Key) verify(digest, signature []byte) bool {
+	return ecdsa.VerifyASN1(k.key, digest, signature)
+}
+
+type rs256Key struct {
+	key *rsa.PublicKey
+}
+
+func (k rs256Key) verify(digest, signature []byte) bool {
+	return rsa.VerifyPKCS1v15(k.key, crypto.SHA256, digest, signature) == nil
+}
+
+// parseCOSEKey reads a COSE_Key as stored with a credential and returns its
+// algorithm and key.
+func parseCOSEKey(raw []byte) (int64, publicKey, error) {
+	decoded, rest, err := decodeCBOR(raw)
+	if err != nil || len(rest) != 0 {
+		return 0, nil, fmt.Errorf("%w: public key is not valid CBOR", ErrInvalid)
+	}
+	m, ok := decoded.(map[any]any)
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: public key is not a COSE key", ErrInvalid)
+	}
+
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseAlgorithm)].(int64)
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		key, err := parseES256Key(m)
+		return alg, key, err
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		key, err := parseRS256Key(m)
+		return alg, key, err
+	default:
+		return 0, nil, fmt.Errorf("%w: algorithm %d is not supported, use ES256 or RS256", ErrInvalid, alg)
+	}
+}
+
+func parseES256Key(m map[any]any) (publicKey, error) {
+	crv, _ := m[int64(coseEC2Curve)].(int64)
+	x, _ := m[int64(coseEC2X)].([]byte)
+	y, _ := m[int64(coseEC2Y)].([]byte)
+	if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+		return nil, fmt.Errorf("%w: ES256 key is not a P-256 point", ErrInvalid)
+	}
+
+	// crypto/ecdh checks that the point is on the curve.
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("%w: ES256 key is not a P-256 point", ErrInvalid)
+	}
+
+	return es256Key{key: &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}}, nil
+}
+
+func parseRS256Key(m map[any]any) (publicKey, error) {
+	n, _ := m[int64(coseRSAModulus)].([]byte)
+	e, _ := m[int64(coseRSAExponent)].([]byte)
+	if len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("%w: RS256 key has no valid exponent", ErrInvalid)
+	}
+
+	modulus := new(big.Int).SetBytes(n)
+	if modulus.BitLen() < minRSABits {
+		return nil, fmt.Errorf("%w: RS256 key is shorter than %d bits", ErrInvalid, minRSABits)
+	}
+
+	exponent := int(new(big.Int).SetBytes(e).Int64())
+	if exponent < 3 || exponent%2 == 0 {
+		return nil, fmt.Errorf("%w: RS256 key has no valid exponent", ErrInvalid)
+	}
+
+	return rs256Key{key: &rsa.PublicKey{N: modulus, E: exponent}}, nil
+}
+
+// verifySignature checks signature over authenticatorData followed by the SHA-256 of
+// clientDataJSON, which is what authenticators sign.
+func verifySignature(key publicKey, authenticatorData, clientDataJSON, signature []byte) bool {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	digest := sha256.Sum256(signed)
+	return key.verify(digest[:], signature)
+}
@@ -0,0 +1,165 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// es256COSEKey encodes the public key of key as a COSE_Key.
+func es256COSEKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	pub, err := key.PublicKey.ECDH()
+	if err != nil {
+		t.Fatalf("ECDH: %v", err)
+	}
+	point := pub.Bytes()
+	return ec2COSEKey(coseCurveP256, point[1:33], point[33:])
+}
+
+func ec2COSEKey(curve int64, x, y []byte) []byte {
+	return cmap(
+		cint(coseKeyType), cint(coseKeyTypeEC2),
+		cint(coseAlgorithm), cint(AlgES256),
+		cint(coseEC2Curve), cint(curve),
+		cint(coseEC2X), cbytes(x),
+		cint(coseEC2Y), cbytes(y),
+	)
+}
+
+func rsaCOSEKey(n, e []byte) []byte {
+	return cmap(
+		cint(coseKeyType), cint(coseKeyTypeRSA),
+		cint(coseAlgorithm), cint(AlgRS256),
+		cint(coseRSAModulus), cbytes(n),
+		cint(coseRSAExponent), cbytes(e),
+	)
+}
+
+func newES256Key(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return key
+}
+
+func TestParseCOSEKey(t *testing.T) {
+	ecKey := newES256Key(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		raw  []byte
+		alg  int64
+	}{
+		{name: "ES256", raw: es256COSEKey(t, ecKey), alg: AlgES256},
+		{name: "RS256", raw: rsaCOSEKey(rsaKey.N.Bytes(), big.NewInt(int64(rsaKey.E)).Bytes()), alg: AlgRS256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alg, key, err := parseCOSEKey(tt.raw)
+			if err != nil {
+				t.Fatalf("parseCOSEKey: %v", err)
+			}
+			if alg != tt.alg || key == nil {
+				t.Errorf("parseCOSEKey = %d, %v, want %d and a key", alg, key, tt.alg)
+			}
+		})
+	}
+}
+
+func TestParseCOSEKeyRejects(t *testing.T) {
+	point := func(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+	valid := es256COSEKey(t, newES256Key(t))
+
+	// 1024 and 2047 bit moduli, and a 2048 bit one for the exponent checks.
+	short := append([]byte{0x80}, make([]byte, 127)...)
+	almost := append([]byte{0x7f}, bytes.Repeat([]byte{0xff}, 255)...)
+	modulus := append([]byte{0x80}, make([]byte, 255)...)
+	modulus[255] = 1
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{name: "not CBOR", raw: []byte{0xff}},
+		{name: "not a map", raw: carray()},
+		{name: "trailing bytes", raw: append(bytes.Clone(valid), 0x00)},
+		{name: "unsupported algorithm", raw: cmap(cint(coseKeyType), cint(1), cint(coseAlgorithm), cint(-8))},
+		{name: "EC2 key with RS256", raw: cmap(cint(coseKeyType), cint(coseKeyTypeEC2), cint(coseAlgorithm), cint(AlgRS256))},
+		{name: "point off the curve", raw: ec2COSEKey(coseCurveP256, point(1), point(1))},
+		{name: "point at zero", raw: ec2COSEKey(coseCurveP256, point(0), point(0))},
+		{name: "other curve", raw: ec2COSEKey(2, point(1), point(1))},
+		{name: "short coordinates", raw: ec2COSEKey(coseCurveP256, point(1)[:31], point(1))},
+		{name: "missing coordinates", raw: cmap(cint(coseKeyType), cint(coseKeyTypeEC2), cint(coseAlgorithm), cint(AlgES256), cint(coseEC2Curve), cint(coseCurveP256))},
+		{name: "1024 bit RSA key", raw: rsaCOSEKey(short, []byte{1, 0, 1})},
+		{name: "2047 bit RSA key", raw: rsaCOSEKey(almost, []byte{1, 0, 1})},
+		{name: "RSA key without exponent", raw: rsaCOSEKey(modulus, nil)},
+		{name: "RSA key with even exponent", raw: rsaCOSEKey(modulus, []byte{4})},
+		{name: "RSA key with exponent 1", raw: rsaCOSEKey(modulus, []byte{1})},
+		{name: "RSA key with long exponent", raw: rsaCOSEKey(modulus, []byte{1, 0, 0, 0, 1})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseCOSEKey(tt.raw); !errors.Is(err, ErrInvalid) {
+				t.Errorf("parseCOSEKey error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	authenticatorData := []byte("authenticator data")
+	clientDataJSON := []byte(`{"type":"webauthn.get"}`)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(bytes.Clone(authenticatorData), clientDataHash[:]...))
+
+	ecKey := newES256Key(t)
+	ecSignature, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	rsaSignature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		key       publicKey
+		signature []byte
+	}{
+		{name: "ES256", key: es256Key{key: &ecKey.PublicKey}, signature: ecSignature},
+		{name: "RS256", key: rs256Key{key: &rsaKey.PublicKey}, signature: rsaSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !verifySignature(tt.key, authenticatorData, clientDataJSON, tt.signature) {
+				t.Error("valid signature doesn't verify")
+			}
+			if verifySignature(tt.key, []byte("other data"), clientDataJSON, tt.signature) {
+				t.Error("signature verifies over other authenticator data")
+			}
+			if verifySignature(tt.key, authenticatorData, []byte(`{}`), tt.signature) {
+				t.Error("signature verifies over other client data")
+			}
+		})
+	}
+}
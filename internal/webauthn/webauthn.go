@@ -0,0 +1,328 @@
+// Package webauthn implements the relying party side of WebAuthn for passkeys: the
+// options handed to the platform authenticator, and the checks of what it sends back.
+// Only "none" attestation is supported, so nothing is learned or trusted about the
+// make of an authenticator, and credentials must use ES256 or RS256.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChallengeTTL is how long a ceremony may take from its options to its response.
+const ChallengeTTL = 5 * time.Minute
+
+// challengeSize is the number of random bytes of a challenge.
+const challengeSize = 32
+
+// maxCredentialIDLength is the longest credential id WebAuthn allows.
+const maxCredentialIDLength = 1023
+
+// Flags of authenticator data.
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagBackupEligible         = 0x08
+	flagBackedUp               = 0x10
+	flagAttestedCredentialData = 0x40
+)
+
+// ErrInvalid is wrapped by every error about a response that doesn't verify.
+var ErrInvalid = errors.New("webauthn response is not valid")
+
+// RelyingParty is this service as WebAuthn sees it. ID is the domain credentials are
+// bound to, and Origins are where responses may come from: web origins such as
+// "https://example.com", and "android:apk-key-hash:..." for Android apps.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// User is the account a credential is registered for. ID is the user handle the
+// authenticator stores and returns on assertion.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// CredentialDescriptor names an existing credential, e.g. to keep an authenticator
+// from registering twice.
+type CredentialDescriptor struct {
+	ID         []byte
+	Transports []string
+}
+
+// Credential is what a verified registration yields.
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte // COSE_Key
+	Algorithm      int64
+	SignCount      uint32
+	AAGUID         uuid.UUID
+	BackupEligible bool
+	BackedUp       bool
+}
+
+// Assertion is what a verified assertion yields.
+type Assertion struct {
+	SignCount uint32
+	BackedUp  bool
+}
+
+// NewChallenge returns a random challenge for a ceremony.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// CreationOptions returns the PublicKeyCredentialCreationOptions of a registration in
+// their JSON form, with binary values as base64url. Passkeys are discoverable and
+// verify the user, so they can sign in without a username or password.
+func (rp RelyingParty) CreationOptions(user User, challenge []byte, exclude []CredentialDescriptor) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"rp": map[string]string{"id": rp.ID, "name": rp.Name},
+		"user": map[string]string{
+			"id":          encode(user.ID),
+			"name":        user.Name,
+			"displayName": user.DisplayName,
+		},
+		"challenge": encode(challenge),
+		"pubKeyCredParams": []map[string]any{
+			{"type": "public-key", "alg": AlgES256},
+			{"type": "public-key", "alg": AlgRS256},
+		},
+		"timeout":            ChallengeTTL.Milliseconds(),
+		"excludeCredentials": descriptors(exclude),
+		"authenticatorSelection": map[string]any{
+			"residentKey":        "required",
+			"requireResidentKey": true,
+			"userVerification":   "required",
+		},
+		"attestation": "none",
+	})
+}
+
+// RequestOptions returns the PublicKeyCredentialRequestOptions of an assertion in
+// their JSON form. No credentials are listed: the authenticator offers the passkeys
+// it holds for the relying party.
+func (rp RelyingParty) RequestOptions(challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"challenge":        encode(challenge),
+		"timeout":          ChallengeTTL.Milliseconds(),
+		"rpId":             rp.ID,
+		"allowCredentials": []any{},
+		"userVerification": "required",
+	})
+}
+
+// VerifyRegistration checks the response to CreationOptions with challenge and
+// returns the new credential.
+func (rp RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return Credential{}, err
+	}
+
+	authData, err := parseAttestationObject(attestationObject)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	data, err := rp.parseAuthenticatorData(authData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if data.flags&flagAttestedCredentialData == 0 {
+		return Credential{}, fmt.Errorf("%w: no credential in authenticator data", ErrInvalid)
+	}
+
+	algorithm, _, err := parseCOSEKey(data.publicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		ID:             data.credentialID,
+		PublicKey:      data.publicKey,
+		Algorithm:      algorithm,
+		SignCount:      data.signCount,
+		AAGUID:         data.aaguid,
+		BackupEligible: data.flags&flagBackupEligible != 0,
+		BackedUp:       data.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// VerifyAssertion checks the response to RequestOptions with challenge against the
+// stored COSE public key of the credential used.
+func (rp RelyingParty) VerifyAssertion(challenge, publicKey, clientDataJSON, authenticatorData, signature []byte) (Assertion, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return Assertion{}, err
+	}
+
+	data, err := rp.parseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	_, key, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return Assertion{}, err
+	}
+	if !verifySignature(key, authenticatorData, clientDataJSON, signature) {
+		return Assertion{}, fmt.Errorf("%w: signature doesn't match", ErrInvalid)
+	}
+
+	return Assertion{
+		SignCount: data.signCount,
+		BackedUp:  data.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// verifyClientData checks the collected client data of a ceremony of type typ.
+func (rp RelyingParty) verifyClientData(clientDataJSON []byte, typ string, challenge []byte) error {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return fmt.Errorf("%w: client data is not JSON", ErrInvalid)
+	}
+
+	if clientData.Type != typ {
+		return fmt.Errorf("%w: client data type is %q, not %q", ErrInvalid, clientData.Type, typ)
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(clientData.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return fmt.Errorf("%w: challenge doesn't match", ErrInvalid)
+	}
+
+	if !slices.Contains(rp.Origins, clientData.Origin) {
+		return fmt.Errorf("%w: origin %q is not allowed", ErrInvalid, clientData.Origin)
+	}
+	return nil
+}
+
+// authenticatorData is the parsed authenticator data of a ceremony. The credential
+// fields are only set when flagAttestedCredentialData is.
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	aaguid       uuid.UUID
+	credentialID []byte
+	publicKey    []byte
+}
+
+// parseAuthenticatorData reads raw authenticator data and checks that it is meant for
+// rp and that the user was both present and verified.
+func (rp RelyingParty) parseAuthenticatorData(raw []byte) (authenticatorData, error) {
+	if len(raw) < 37 {
+		return authenticatorData{}, fmt.Errorf("%w: authenticator data is too short", ErrInvalid)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(raw[:32], rpIDHash[:]) {
+		return authenticatorData{}, fmt.Errorf("%w: credential is for another relying party", ErrInvalid)
+	}
+
+	data := authenticatorData{
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	if data.flags&flagUserPresent == 0 || data.flags&flagUserVerified == 0 {
+		return authenticatorData{}, fmt.Errorf("%w: user was not verified", ErrInvalid)
+	}
+
+	if data.flags&flagAttestedCredentialData != 0 {
+		if err := data.parseAttestedCredential(raw[37:]); err != nil {
+			return authenticatorData{}, err
+		}
+	}
+	return data, nil
+}
+
+// parseAttestedCredential reads the AAGUID, id and public key of a new credential.
+// Extension outputs that may follow are ignored.
+func (d *authenticatorData) parseAttestedCredential(raw []byte) error {
+	if len(raw) < 18 {
+		return fmt.Errorf("%w: attested credential data is too short", ErrInvalid)
+	}
+
+	aaguid, err := uuid.FromBytes(raw[:16])
+	if err != nil {
+		return fmt.Errorf("%w: attested credential data has no AAGUID", ErrInvalid)
+	}
+
+	idLength := int(binary.BigEndian.Uint16(raw[16:18]))
+	raw = raw[18:]
+	if idLength == 0 || idLength > maxCredentialIDLength || idLength > len(raw) {
+		return fmt.Errorf("%w: credential id has a bad length", ErrInvalid)
+	}
+
+	_, rest, err := decodeCBOR(raw[idLength:])
+	if err != nil {
+		return fmt.Errorf("%w: credential public key is not valid CBOR", ErrInvalid)
+	}
+
+	d.aaguid = aaguid
+	d.credentialID = bytes.Clone(raw[:idLength])
+	d.publicKey = bytes.Clone(raw[idLength : len(raw)-len(rest)])
+	return nil
+}
+
+// parseAttestationObject returns the authenticator data of an attestation object,
+// accepting only "none" attestation.
+func parseAttestationObject(raw []byte) ([]byte, error) {
+	decoded, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object is not valid CBOR", ErrInvalid)
+	}
+	m, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object is not a map", ErrInvalid)
+	}
+
+	format, _ := m["fmt"].(string)
+	statement, _ := m["attStmt"].(map[any]any)
+	if format != "none" || len(statement) != 0 {
+		return nil, fmt.Errorf("%w: attestation %q is not supported, request \"none\"", ErrInvalid, format)
+	}
+
+	authData, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object has no authenticator data", ErrInvalid)
+	}
+	return authData, nil
+}
+
+func descriptors(credentials []CredentialDescriptor) []map[string]any {
+	out := make([]map[string]any, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptor := map[string]any{"type": "public-key", "id": encode(credential.ID)}
+		if len(credential.Transports) > 0 {
+			descriptor["transports"] = credential.Transports
+		}
+		out = append(out, descriptor)
+	}
+	return out
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
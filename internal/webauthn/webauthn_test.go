@@ -0,0 +1,294 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var testRP = RelyingParty{
+	ID:      "example.com",
+	Name:    "Example",
+	Origins: []string{"https://example.com", "android:apk-key-hash:abc"},
+}
+
+var testAAGUID = uuid.MustParse("adce0002-35bc-c60a-648b-0b25f1f05503")
+
+// ceremony holds what an authenticator and a browser put together in a response. A
+// test starts from a valid one and breaks a single part of it.
+type ceremony struct {
+	typ       string
+	challenge []byte
+	origin    string
+
+	rpID      string
+	flags     byte
+	signCount uint32
+
+	credentialID []byte
+	publicKey    []byte
+
+	format    string
+	statement []byte
+}
+
+func newRegistration(t *testing.T, challenge []byte) ceremony {
+	return ceremony{
+		typ:          "webauthn.create",
+		challenge:    challenge,
+		origin:       "https://example.com",
+		rpID:         testRP.ID,
+		flags:        flagUserPresent | flagUserVerified | flagBackupEligible | flagAttestedCredentialData,
+		credentialID: []byte("credential-1"),
+		publicKey:    es256COSEKey(t, newES256Key(t)),
+		format:       "none",
+		statement:    cmap(),
+	}
+}
+
+func newAssertion(challenge []byte) ceremony {
+	return ceremony{
+		typ:       "webauthn.get",
+		challenge: challenge,
+		origin:    "https://example.com",
+		rpID:      testRP.ID,
+		flags:     flagUserPresent | flagUserVerified,
+		signCount: 7,
+	}
+}
+
+func (c ceremony) clientDataJSON() []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      c.typ,
+		"challenge": base64.RawURLEncoding.EncodeToString(c.challenge),
+		"origin":    c.origin,
+	})
+	return data
+}
+
+func (c ceremony) authenticatorData() []byte {
+	rpIDHash := sha256.Sum256([]byte(c.rpID))
+	data := append(rpIDHash[:], c.flags)
+	data = binary.BigEndian.AppendUint32(data, c.signCount)
+	if c.flags&flagAttestedCredentialData == 0 {
+		return data
+	}
+
+	data = append(data, testAAGUID[:]...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(c.credentialID)))
+	data = append(data, c.credentialID...)
+	return append(data, c.publicKey...)
+}
+
+func (c ceremony) attestationObject() []byte {
+	return cmap(
+		ctext("fmt"), ctext(c.format),
+		ctext("attStmt"), c.statement,
+		ctext("authData"), cbytes(c.authenticatorData()),
+	)
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, authenticatorData, clientDataJSON []byte) []byte {
+	t.Helper()
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(bytes.Clone(authenticatorData), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1: %v", err)
+	}
+	return signature
+}
+
+func newTestChallenge(t *testing.T) []byte {
+	t.Helper()
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	return challenge
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	challenge := newTestChallenge(t)
+	registration := newRegistration(t, challenge)
+
+	credential, err := testRP.VerifyRegistration(challenge, registration.clientDataJSON(), registration.attestationObject())
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+
+	if !bytes.Equal(credential.ID, registration.credentialID) {
+		t.Errorf("ID = %q, want %q", credential.ID, registration.credentialID)
+	}
+	if !bytes.Equal(credential.PublicKey, registration.publicKey) {
+		t.Error("PublicKey isn't the COSE key of the authenticator data")
+	}
+	if credential.Algorithm != AlgES256 {
+		t.Errorf("Algorithm = %d, want %d", credential.Algorithm, AlgES256)
+	}
+	if credential.AAGUID != testAAGUID {
+		t.Errorf("AAGUID = %s, want %s", credential.AAGUID, testAAGUID)
+	}
+	if !credential.BackupEligible || credential.BackedUp {
+		t.Errorf("BackupEligible, BackedUp = %t, %t, want true, false", credential.BackupEligible, credential.BackedUp)
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	challenge := newTestChallenge(t)
+
+	tests := []struct {
+		name   string
+		change func(c *ceremony)
+	}{
+		{name: "assertion type", change: func(c *ceremony) { c.typ = "webauthn.get" }},
+		{name: "other challenge", change: func(c *ceremony) { c.challenge = newTestChallenge(t) }},
+		{name: "other origin", change: func(c *ceremony) { c.origin = "https://evil.example" }},
+		{name: "origin of a subdomain", change: func(c *ceremony) { c.origin = "https://login.example.com" }},
+		{name: "other relying party", change: func(c *ceremony) { c.rpID = "evil.example" }},
+		{name: "user not present", change: func(c *ceremony) { c.flags &^= flagUserPresent }},
+		{name: "user not verified", change: func(c *ceremony) { c.flags &^= flagUserVerified }},
+		{name: "no attested credential", change: func(c *ceremony) { c.flags &^= flagAttestedCredentialData }},
+		{name: "packed attestation", change: func(c *ceremony) { c.format = "packed" }},
+		{name: "none attestation with a statement", change: func(c *ceremony) { c.statement = cmap(ctext("alg"), cint(AlgES256)) }},
+		{name: "empty credential id", change: func(c *ceremony) { c.credentialID = nil }},
+		{name: "credential id too long", change: func(c *ceremony) { c.credentialID = make([]byte, maxCredentialIDLength+1) }},
+		{name: "unsupported algorithm", change: func(c *ceremony) {
+			c.publicKey = cmap(cint(coseKeyType), cint(1), cint(coseAlgorithm), cint(-8))
+		}},
+		{name: "public key off the curve", change: func(c *ceremony) {
+			c.publicKey = ec2COSEKey(coseCurveP256, bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 32))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registration := newRegistration(t, challenge)
+			tt.change(&registration)
+
+			_, err := testRP.VerifyRegistration(challenge, registration.clientDataJSON(), registration.attestationObject())
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("VerifyRegistration error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationRejectsMalformed(t *testing.T) {
+	challenge := newTestChallenge(t)
+	registration := newRegistration(t, challenge)
+	clientDataJSON := registration.clientDataJSON()
+	authData := registration.authenticatorData()
+
+	tests := []struct {
+		name              string
+		clientDataJSON    []byte
+		attestationObject []byte
+	}{
+		{name: "client data not JSON", clientDataJSON: []byte("{"), attestationObject: registration.attestationObject()},
+		{name: "attestation object not CBOR", clientDataJSON: clientDataJSON, attestationObject: []byte{0xff}},
+		{name: "attestation object not a map", clientDataJSON: clientDataJSON, attestationObject: carray()},
+		{name: "no authenticator data", clientDataJSON: clientDataJSON, attestationObject: cmap(
+			ctext("fmt"), ctext("none"),
+			ctext("attStmt"), cmap(),
+		)},
+		{name: "authenticator data too short", clientDataJSON: clientDataJSON, attestationObject: cmap(
+			ctext("fmt"), ctext("none"),
+			ctext("attStmt"), cmap(),
+			ctext("authData"), cbytes(authData[:36]),
+		)},
+		{name: "attested credential cut short", clientDataJSON: clientDataJSON, attestationObject: cmap(
+			ctext("fmt"), ctext("none"),
+			ctext("attStmt"), cmap(),
+			ctext("authData"), cbytes(authData[:len(authData)-1]),
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testRP.VerifyRegistration(challenge, tt.clientDataJSON, tt.attestationObject)
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("VerifyRegistration error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	key := newES256Key(t)
+	challenge := newTestChallenge(t)
+
+	assertion := newAssertion(challenge)
+	assertion.origin = "android:apk-key-hash:abc"
+	assertion.flags |= flagBackedUp
+	authData := assertion.authenticatorData()
+	clientDataJSON := assertion.clientDataJSON()
+
+	got, err := testRP.VerifyAssertion(challenge, es256COSEKey(t, key), clientDataJSON, authData, sign(t, key, authData, clientDataJSON))
+	if err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+	if got.SignCount != assertion.signCount || !got.BackedUp {
+		t.Errorf("VerifyAssertion = %+v, want sign count %d and backed up", got, assertion.signCount)
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	key := newES256Key(t)
+	otherKey := newES256Key(t)
+	publicKey := es256COSEKey(t, key)
+	challenge := newTestChallenge(t)
+
+	tests := []struct {
+		name      string
+		change    func(c *ceremony)
+		signer    *ecdsa.PrivateKey
+		publicKey []byte
+		// tamper changes the authenticator data after it was signed.
+		tamper func(authData []byte)
+	}{
+		{name: "registration type", change: func(c *ceremony) { c.typ = "webauthn.create" }},
+		{name: "other challenge", change: func(c *ceremony) { c.challenge = newTestChallenge(t) }},
+		{name: "other origin", change: func(c *ceremony) { c.origin = "https://evil.example" }},
+		{name: "other relying party", change: func(c *ceremony) { c.rpID = "evil.example" }},
+		{name: "user not present", change: func(c *ceremony) { c.flags &^= flagUserPresent }},
+		{name: "user not verified", change: func(c *ceremony) { c.flags &^= flagUserVerified }},
+		{name: "signed by another key", signer: otherKey},
+		{name: "sign count changed after signing", tamper: func(authData []byte) { authData[36]++ }},
+		{name: "stored key is not a COSE key", publicKey: []byte{0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion := newAssertion(challenge)
+			if tt.change != nil {
+				tt.change(&assertion)
+			}
+			signer := key
+			if tt.signer != nil {
+				signer = tt.signer
+			}
+			storedKey := publicKey
+			if tt.publicKey != nil {
+				storedKey = tt.publicKey
+			}
+
+			authData := assertion.authenticatorData()
+			clientDataJSON := assertion.clientDataJSON()
+			signature := sign(t, signer, authData, clientDataJSON)
+			if tt.tamper != nil {
+				tt.tamper(authData)
+			}
+
+			_, err := testRP.VerifyAssertion(challenge, storedKey, clientDataJSON, authData, signature)
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("VerifyAssertion error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
@@ -1,16 +1,33 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	authService "github.com/imhasandl/auth-service/cmd/auth"
 
 	_ "github.com/lib/pq" // Import the postgres driver
 
 	"github.com/imhasandl/user-service/cmd/server"
+	"github.com/imhasandl/user-service/internal/auth"
 	"github.com/imhasandl/user-service/internal/database"
+	"github.com/imhasandl/user-service/internal/dataexport"
+	"github.com/imhasandl/user-service/internal/mail"
+	"github.com/imhasandl/user-service/internal/password"
+	"github.com/imhasandl/user-service/internal/purge"
 	"github.com/imhasandl/user-service/internal/rabbitmq"
+	"github.com/imhasandl/user-service/internal/totp"
+	"github.com/imhasandl/user-service/internal/webauthn"
 	pb "github.com/imhasandl/user-service/protos"
 	"github.com/joho/godotenv"
 	"google.golang.org/grpc"
@@ -25,9 +42,43 @@ type Config struct {
 	EmailSecret string
 	TokenSecret string
 	RabbitMQURL string
+	Environment string
+
+	PasswordMinLength     string
+	BreachedPasswordsFile string
+
+	MailBackend      string
+	MailFrom         string
+	MailDir          string
+	SMTPAddr         string
+	SMTPUsername     string
+	SMTPPassword     string
+	PasswordResetURL string
+
+	// TOTPEncryptionKey is the base64 key TOTP secrets are sealed with, and TOTPIssuer
+	// the name authenticator apps show for this service.
+	TOTPEncryptionKey string
+	TOTPIssuer        string
+
+	// WebAuthnRPID is the domain passkeys are bound to; passkeys are off without it.
+	// WebAuthnOrigins lists where passkey responses may come from, comma separated.
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins string
+
+	// AdminUserIDs are made administrators at startup, to create the first admin.
+	AdminUserIDs []uuid.UUID
+
+	// PurgeInterval is how often deleted accounts past their grace period are purged.
+	PurgeInterval time.Duration
+	// DeviceTokenTTL is how long a device token is kept after the app last registered it.
+	DeviceTokenTTL time.Duration
+	// DataExportInterval is how often pending data exports are looked for, on top of
+	// the ones built as soon as they are requested.
+	DataExportInterval time.Duration
 }
 
-// loadConfig loads configuration from environment variables
+// loadConfig loads configuration from environment variables and validates it
 func loadConfig() (Config, error) {
 	if err := godotenv.Load(".env"); err != nil {
 		return Config{}, err
@@ -40,9 +91,56 @@ func loadConfig() (Config, error) {
 		EmailSecret: os.Getenv("EMAIL_SECRET"),
 		TokenSecret: os.Getenv("TOKEN_SECRET"),
 		RabbitMQURL: os.Getenv("RABBITMQ_URL"),
+		Environment: os.Getenv("APP_ENV"),
+
+		PasswordMinLength:     os.Getenv("PASSWORD_MIN_LENGTH"),
+		BreachedPasswordsFile: os.Getenv("BREACHED_PASSWORDS_FILE"),
+
+		MailBackend:      envOr("MAIL_BACKEND", "smtp"),
+		MailDir:          envOr("MAIL_DIR", "mail"),
+		SMTPAddr:         os.Getenv("SMTP_ADDR"),
+		PasswordResetURL: os.Getenv("PASSWORD_RESET_URL"),
+
+		TOTPEncryptionKey: os.Getenv("TOTP_ENCRYPTION_KEY"),
+		TOTPIssuer:        envOr("TOTP_ISSUER", "user-service"),
+
+		WebAuthnRPID:   os.Getenv("WEBAUTHN_RP_ID"),
+		WebAuthnRPName: envOr("WEBAUTHN_RP_NAME", "user-service"),
+	}
+
+	purgeInterval, err := time.ParseDuration(envOr("PURGE_INTERVAL", "1h"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid PURGE_INTERVAL: %w", err)
+	}
+	config.PurgeInterval = purgeInterval
+
+	deviceTokenTTLDays, err := strconv.Atoi(envOr("DEVICE_TOKEN_TTL_DAYS", "60"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid DEVICE_TOKEN_TTL_DAYS: %w", err)
+	}
+	config.DeviceTokenTTL = time.Duration(deviceTokenTTLDays) * 24 * time.Hour
+
+	dataExportInterval, err := time.ParseDuration(envOr("DATA_EXPORT_INTERVAL", "1m"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid DATA_EXPORT_INTERVAL: %w", err)
+	}
+	config.DataExportInterval = dataExportInterval
+
+	adminUserIDs, err := parseUserIDs(os.Getenv("ADMIN_USER_IDS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid ADMIN_USER_IDS: %w", err)
 	}
+	config.AdminUserIDs = adminUserIDs
+
+	config.WebAuthnOrigins = envOr("WEBAUTHN_ORIGINS", "https://"+config.WebAuthnRPID)
+
+	// EMAIL and EMAIL_SECRET used to be the only mail settings; they still work as the
+	// sender and the SMTP credentials.
+	config.MailFrom = envOr("MAIL_FROM", config.Email)
+	config.SMTPUsername = envOr("SMTP_USERNAME", config.Email)
+	config.SMTPPassword = envOr("SMTP_PASSWORD", config.EmailSecret)
 
-	return config, nil
+	return config, validateConfig(config)
 }
 
 // validateConfig ensures all required configuration values are set
@@ -55,14 +153,10 @@ func validateConfig(config Config) error {
 		return log.Output(1, "Set db connection in env")
 	}
 
-	if config.Email == "" {
+	if config.MailFrom == "" {
 		return log.Output(1, "Set working email for sending emails")
 	}
 
-	if config.EmailSecret == "" {
-		return log.Output(1, "Set up Email Secret")
-	}
-
 	if config.TokenSecret == "" {
 		return log.Output(1, "Set db connection in env")
 	}
@@ -71,17 +165,171 @@ func validateConfig(config Config) error {
 		return log.Output(1, "Set rabbitmq url in env")
 	}
 
+	if config.PurgeInterval <= 0 {
+		return log.Output(1, "Set a positive PURGE_INTERVAL in env")
+	}
+
+	if config.DeviceTokenTTL <= 0 {
+		return log.Output(1, "Set a positive DEVICE_TOKEN_TTL_DAYS in env")
+	}
+
+	if config.DataExportInterval <= 0 {
+		return log.Output(1, "Set a positive DATA_EXPORT_INTERVAL in env")
+	}
+
 	return nil
 }
 
+// envOr returns the environment variable key, or fallback when it is unset.
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// parseUserIDs parses a comma separated list of user ids.
+func parseUserIDs(raw string) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	for _, field := range strings.Split(raw, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		userID, err := uuid.Parse(field)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, nil
+}
+
+// openDatabase connects to DB_URL and makes the users in ADMIN_USER_IDS admins.
+func openDatabase(config Config) (*sql.DB, *database.Queries, error) {
+	dbConn, err := sql.Open("postgres", config.DBURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	dbQueries := database.New(dbConn)
+
+	if err := server.BootstrapAdmins(context.Background(), dbQueries, config.AdminUserIDs); err != nil {
+		dbConn.Close()
+		return nil, nil, fmt.Errorf("can't make ADMIN_USER_IDS admins: %w", err)
+	}
+	return dbConn, dbQueries, nil
+}
+
+// newMailer creates the mail backend picked by MAIL_BACKEND: "smtp" sends real emails,
+// "file" writes them to MAIL_DIR as .eml files for local development.
+func newMailer(config Config) (mail.Mailer, error) {
+	switch config.MailBackend {
+	case "smtp":
+		if config.SMTPAddr == "" {
+			return nil, errors.New("set SMTP_ADDR to send emails over SMTP")
+		}
+		return mail.NewSMTPMailer(config.SMTPAddr, config.SMTPUsername, config.SMTPPassword, config.MailFrom)
+	case "file":
+		return mail.NewFileMailer(config.MailDir, config.MailFrom)
+	default:
+		return nil, fmt.Errorf("unknown MAIL_BACKEND %q, use smtp or file", config.MailBackend)
+	}
+}
+
+// allowsDeleteAllUsers reports whether the DeleteAllUsers RPC may run in environment.
+// It is only enabled in environments explicitly marked as development or test, so a
+// production deployment that forgets to set APP_ENV stays safe.
+func allowsDeleteAllUsers(environment string) bool {
+	return environment == "development" || environment == "test"
+}
+
+// defaultPasswordMinLength is used when PASSWORD_MIN_LENGTH is not set.
+const defaultPasswordMinLength = 8
+
+// newPasswordPolicy builds the rules new passwords must pass: a minimum length, no
+// password from the breached list if one is configured, and no reuse of recent ones.
+func newPasswordPolicy(config Config) (password.Policy, error) {
+	minLength := defaultPasswordMinLength
+	if config.PasswordMinLength != "" {
+		n, err := strconv.Atoi(config.PasswordMinLength)
+		if err != nil {
+			return nil, err
+		}
+		minLength = n
+	}
+
+	policy := password.Policy{password.MinLength(minLength)}
+
+	if config.BreachedPasswordsFile != "" {
+		breached, err := password.LoadBreachedList(config.BreachedPasswordsFile)
+		if err != nil {
+			return nil, err
+		}
+		policy = append(policy, password.Breached(breached))
+	}
+
+	return append(policy, password.NoReuse(authService.CheckPassword)), nil
+}
+
+// newTOTPCipher creates the cipher TOTP secrets are sealed with from the base64
+// TOTP_ENCRYPTION_KEY, which must decode to totp.KeySize bytes. It returns nil when
+// the key isn't set and two-factor authentication is off.
+func newTOTPCipher(config Config) (*totp.Cipher, error) {
+	if config.TOTPEncryptionKey == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(config.TOTPEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY is not base64: %w", err)
+	}
+	return totp.NewCipher(key)
+}
+
+// newRelyingParty describes this service to passkeys, or returns nil when
+// WEBAUTHN_RP_ID isn't set and passkeys are off.
+func newRelyingParty(config Config) *webauthn.RelyingParty {
+	if config.WebAuthnRPID == "" {
+		return nil
+	}
+
+	var origins []string
+	for _, origin := range strings.Split(config.WebAuthnOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	return &webauthn.RelyingParty{
+		ID:      config.WebAuthnRPID,
+		Name:    config.WebAuthnRPName,
+		Origins: origins,
+	}
+}
+
 func main() {
 	config, err := loadConfig()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	passwordPolicy, err := newPasswordPolicy(config)
+	if err != nil {
+		log.Fatalf("Can't set up password policy: %v", err)
+	}
+
+	totpCipher, err := newTOTPCipher(config)
+	if err != nil {
+		log.Fatalf("Can't set up totp encryption: %v", err)
+	}
+
+	mailer, err := newMailer(config)
+	if err != nil {
+		log.Fatalf("Can't set up mailer: %v", err)
 	}
 
-	if err := validateConfig(config); err != nil {
-		log.Fatal(err)
+	mailTemplates, err := mail.DefaultTemplates()
+	if err != nil {
+		log.Fatalf("Can't load mail templates: %v", err)
 	}
 
 	lis, err := net.Listen("tcp", config.Port)
@@ -89,11 +337,10 @@ func main() {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	dbConn, err := sql.Open("postgres", config.DBURL)
+	dbConn, dbQueries, err := openDatabase(config)
 	if err != nil {
 		log.Fatalf("Error opening database: %s", err)
 	}
-	dbQueries := database.New(dbConn)
 	defer dbConn.Close()
 
 	rabbitmq, err := rabbitmq.NewRabbitMQ(config.RabbitMQURL)
@@ -101,9 +348,41 @@ func main() {
 		log.Fatal("Can't connect to rabbitmq")
 	}
 
-	server := server.NewServer(dbQueries, config.TokenSecret, config.Email, config.EmailSecret, rabbitmq)
+	// Deleted accounts are purged once their grace period is over, and stale device
+	// tokens once DEVICE_TOKEN_TTL_DAYS have passed.
+	go purge.NewPurger(dbConn, dbQueries, rabbitmq, config.DeviceTokenTTL).Run(context.Background(), config.PurgeInterval)
+
+	dataExports := dataexport.NewWorker(dbConn, dbQueries)
+	go dataExports.Run(context.Background(), config.DataExportInterval)
+
+	authInterceptor := auth.NewInterceptor(
+		config.TokenSecret,
+		pb.UserService_ServiceDesc.ServiceName,
+		server.MethodPolicies,
+		server.NewRoleResolver(dbQueries),
+		auth.RequireVerified(server.NewVerifiedResolver(dbQueries), server.VerifiedMethods...),
+	)
+
+	server := server.NewServer(
+		dbConn,
+		dbQueries,
+		config.TokenSecret,
+		mailer,
+		mailTemplates,
+		config.PasswordResetURL,
+		rabbitmq,
+		passwordPolicy,
+		dataExports,
+		totpCipher,
+		config.TOTPIssuer,
+		newRelyingParty(config),
+		allowsDeleteAllUsers(config.Environment),
+	)
 
-	s := grpc.NewServer()
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authInterceptor.Unary()),
+		grpc.ChainStreamInterceptor(authInterceptor.Stream()),
+	)
 	pb.RegisterUserServiceServer(s, server)
 
 	reflection.Register(s)
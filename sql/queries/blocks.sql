@@ -0,0 +1,34 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, kind, created_at)
+VALUES ($1, $2, 'block', NOW())
+ON CONFLICT (blocker_id, blocked_id) DO UPDATE
+SET kind = 'block', created_at = EXCLUDED.created_at
+WHERE user_blocks.kind <> 'block';
+
+-- name: MuteUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, kind, created_at)
+VALUES ($1, $2, 'mute', NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+   SELECT 1 FROM user_blocks
+   WHERE kind = 'block'
+     AND ((blocker_id = sqlc.arg(user_a) AND blocked_id = sqlc.arg(user_b))
+       OR (blocker_id = sqlc.arg(user_b) AND blocked_id = sqlc.arg(user_a)))
+) AS blocked;
+
+-- name: ListBlockedUsers :many
+SELECT sqlc.embed(users), user_blocks.kind, user_blocks.created_at AS blocked_at
+FROM user_blocks
+JOIN users ON users.id = user_blocks.blocked_id
+WHERE user_blocks.blocker_id = sqlc.arg(user_id)
+  AND users.deleted_at IS NULL
+  AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+       OR (user_blocks.created_at, user_blocks.blocked_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY user_blocks.created_at DESC, user_blocks.blocked_id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,54 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, user_id, status, created_at)
+VALUES ($1, $2, 'pending', $3)
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetLatestDataExport :one
+SELECT * FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ClaimDataExport :one
+-- Marks the oldest export waiting to be built, or one whose builder died, as running.
+-- SKIP LOCKED lets several workers claim exports side by side.
+UPDATE data_exports
+SET status = 'running', started_at = sqlc.arg(started_at)
+WHERE id = (
+   SELECT id FROM data_exports
+   WHERE status = 'pending'
+      OR (status = 'running' AND started_at < sqlc.arg(stale_before)::timestamp)
+   ORDER BY created_at
+   LIMIT 1
+   FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'ready', size_bytes = $2, completed_at = $3, expires_at = $4
+WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', error = $2, completed_at = $3
+WHERE id = $1;
+
+-- name: DeleteExpiredDataExports :execrows
+DELETE FROM data_exports
+WHERE expires_at < sqlc.arg(now)::timestamp;
+
+-- name: StoreDataExportArchive :exec
+INSERT INTO data_export_archives (export_id, archive)
+VALUES ($1, $2);
+
+-- name: ReadDataExportChunk :one
+-- Reads chunk_size bytes of an archive starting at chunk_start, counted from 1, so a
+-- download never holds the whole archive in memory.
+SELECT substring(archive FROM sqlc.arg(chunk_start)::int FOR sqlc.arg(chunk_size)::int)::bytea AS chunk
+FROM data_export_archives
+WHERE export_id = sqlc.arg(export_id);
@@ -0,0 +1,32 @@
+-- name: RegisterDeviceToken :one
+-- A token registered again moves to the user registering it, since it now belongs to
+-- whoever is signed in on that device.
+INSERT INTO device_tokens (id, user_id, device_token, device_type, created_at, updated_at, last_seen_at)
+VALUES (sqlc.arg(id), sqlc.arg(user_id), sqlc.arg(device_token), sqlc.arg(device_type), sqlc.arg(now), sqlc.arg(now), sqlc.arg(now))
+ON CONFLICT (device_token) DO UPDATE
+SET user_id = EXCLUDED.user_id,
+    device_type = EXCLUDED.device_type,
+    created_at = CASE WHEN device_tokens.user_id = EXCLUDED.user_id THEN device_tokens.created_at ELSE EXCLUDED.created_at END,
+    updated_at = EXCLUDED.updated_at,
+    last_seen_at = EXCLUDED.last_seen_at
+RETURNING *;
+
+-- name: UnregisterDeviceToken :exec
+DELETE FROM device_tokens
+WHERE user_id = $1 AND device_token = $2;
+
+-- name: ListDeviceTokens :many
+SELECT * FROM device_tokens
+WHERE user_id = $1
+ORDER BY last_seen_at DESC;
+
+-- name: GetDeviceTokensForUsers :many
+SELECT device_tokens.* FROM device_tokens
+JOIN users ON users.id = device_tokens.user_id
+WHERE device_tokens.user_id = ANY(sqlc.arg(user_ids)::uuid[])
+  AND users.deleted_at IS NULL
+ORDER BY device_tokens.user_id, device_tokens.last_seen_at DESC;
+
+-- name: PruneDeviceTokens :execrows
+DELETE FROM device_tokens
+WHERE last_seen_at < $1;
@@ -0,0 +1,36 @@
+-- name: GetEmailChangeRequest :one
+SELECT * FROM email_change_requests
+WHERE user_id = $1;
+
+-- name: UpsertEmailChangeRequest :exec
+INSERT INTO email_change_requests (user_id, new_email, code_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE
+SET new_email = EXCLUDED.new_email,
+    code_hash = EXCLUDED.code_hash,
+    attempts = 0,
+    expires_at = EXCLUDED.expires_at,
+    created_at = EXCLUDED.created_at;
+
+-- name: RecordEmailChangeAttempt :one
+UPDATE email_change_requests
+SET attempts = attempts + 1
+WHERE user_id = $1
+RETURNING attempts;
+
+-- name: DeleteEmailChangeRequest :execrows
+DELETE FROM email_change_requests
+WHERE user_id = $1 AND code_hash = $2;
+
+-- name: IsEmailTaken :one
+SELECT EXISTS (
+   SELECT 1 FROM users
+   WHERE lower(email) = lower($1)
+) AS taken;
+
+-- name: ChangeEmail :one
+-- The code sent to the new address proves the user owns it.
+UPDATE users
+SET email = $2, is_verified = TRUE, verified_at = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
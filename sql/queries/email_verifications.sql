@@ -0,0 +1,25 @@
+-- name: GetEmailVerification :one
+SELECT * FROM email_verifications
+WHERE user_id = $1;
+
+-- name: StoreEmailVerification :execrows
+-- The cooldown is checked in the upsert, so two concurrent requests can't both send
+-- a code.
+INSERT INTO email_verifications (user_id, code_hash, expires_at, sent_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(code_hash), sqlc.arg(expires_at), sqlc.arg(sent_at))
+ON CONFLICT (user_id) DO UPDATE
+SET code_hash = EXCLUDED.code_hash,
+    attempts = 0,
+    expires_at = EXCLUDED.expires_at,
+    sent_at = EXCLUDED.sent_at
+WHERE email_verifications.sent_at <= sqlc.arg(sent_before);
+
+-- name: RecordEmailVerificationAttempt :one
+UPDATE email_verifications
+SET attempts = attempts + 1
+WHERE user_id = $1
+RETURNING attempts;
+
+-- name: DeleteEmailVerification :exec
+DELETE FROM email_verifications
+WHERE user_id = $1;
@@ -0,0 +1,35 @@
+-- name: CreateFollowRequest :execrows
+INSERT INTO follow_requests (requester_id, target_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (requester_id, target_id) DO NOTHING;
+
+-- name: DeleteFollowRequest :execrows
+DELETE FROM follow_requests
+WHERE requester_id = $1 AND target_id = $2;
+
+-- name: DeleteFollowRequestsBetween :exec
+DELETE FROM follow_requests
+WHERE (requester_id = sqlc.arg(user_a) AND target_id = sqlc.arg(user_b))
+   OR (requester_id = sqlc.arg(user_b) AND target_id = sqlc.arg(user_a));
+
+-- name: ListFollowRequests :many
+SELECT sqlc.embed(users), follow_requests.created_at AS requested_at
+FROM follow_requests
+JOIN users ON users.id = follow_requests.requester_id
+WHERE follow_requests.target_id = sqlc.arg(user_id)
+  AND users.deleted_at IS NULL
+  AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+       OR (follow_requests.created_at, follow_requests.requester_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY follow_requests.created_at DESC, follow_requests.requester_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: AcceptAllFollowRequests :many
+WITH accepted AS (
+   DELETE FROM follow_requests
+   WHERE target_id = $1
+   RETURNING requester_id, target_id
+)
+INSERT INTO follows (follower_id, followee_id, created_at)
+SELECT requester_id, target_id, NOW() FROM accepted
+ON CONFLICT (follower_id, followee_id) DO NOTHING
+RETURNING follower_id;
@@ -0,0 +1,91 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollow :one
+SELECT * FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: ListFollowers :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.follower_id
+WHERE follows.followee_id = sqlc.arg(user_id)
+  AND users.deleted_at IS NULL
+  AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+       OR (follows.created_at, follows.follower_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY follows.created_at DESC, follows.follower_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFollowing :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg(user_id)
+  AND users.deleted_at IS NULL
+  AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+       OR (follows.created_at, follows.followee_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY follows.created_at DESC, follows.followee_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetFollowCounts :one
+SELECT
+   (SELECT COUNT(*) FROM follows
+    JOIN users ON users.id = follows.follower_id
+    WHERE follows.followee_id = sqlc.arg(user_id) AND users.deleted_at IS NULL) AS followers_count,
+   (SELECT COUNT(*) FROM follows
+    JOIN users ON users.id = follows.followee_id
+    WHERE follows.follower_id = sqlc.arg(user_id) AND users.deleted_at IS NULL) AS following_count;
+
+-- name: ListMutualFollowers :many
+SELECT users.* FROM users
+JOIN follows AS follows_a ON follows_a.follower_id = users.id AND follows_a.followee_id = sqlc.arg(user_a)
+JOIN follows AS follows_b ON follows_b.follower_id = users.id AND follows_b.followee_id = sqlc.arg(user_b)
+WHERE users.deleted_at IS NULL
+ORDER BY users.username, users.id
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: SuggestUsers :many
+-- Candidates are friends of friends, followers not followed back and recent sign-ups,
+-- minus anyone the user blocked or muted and anyone who blocked the user.
+-- Shared connections weigh most, then following you, then joining in the last 30 days.
+WITH my_following AS (
+   SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(user_id)
+), my_followers AS (
+   SELECT follower_id FROM follows WHERE followee_id = sqlc.arg(user_id)
+), friends_of_friends AS (
+   SELECT follows.followee_id AS candidate_id, COUNT(*) AS mutual_follows_count
+   FROM follows
+   JOIN my_following ON my_following.followee_id = follows.follower_id
+   GROUP BY follows.followee_id
+)
+SELECT sqlc.embed(users),
+   COALESCE(friends_of_friends.mutual_follows_count, 0)::bigint AS mutual_follows_count,
+   (my_followers.follower_id IS NOT NULL)::boolean AS follows_you
+FROM users
+LEFT JOIN friends_of_friends ON friends_of_friends.candidate_id = users.id
+LEFT JOIN my_followers ON my_followers.follower_id = users.id
+WHERE users.id <> sqlc.arg(user_id)
+  AND users.deleted_at IS NULL
+  AND users.id NOT IN (SELECT followee_id FROM my_following)
+  AND users.id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = sqlc.arg(user_id))
+  AND users.id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = sqlc.arg(user_id) AND kind = 'block')
+  AND (friends_of_friends.candidate_id IS NOT NULL
+       OR my_followers.follower_id IS NOT NULL
+       OR users.created_at > NOW() - INTERVAL '30 days')
+ORDER BY
+   COALESCE(friends_of_friends.mutual_follows_count, 0) * 3
+      + CASE WHEN my_followers.follower_id IS NOT NULL THEN 2 ELSE 0 END
+      + CASE WHEN users.created_at > NOW() - INTERVAL '30 days' THEN 1 ELSE 0 END DESC,
+   users.created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg(user_a) AND followee_id = sqlc.arg(user_b))
+   OR (follower_id = sqlc.arg(user_b) AND followee_id = sqlc.arg(user_a));
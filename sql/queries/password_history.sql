@@ -0,0 +1,18 @@
+-- name: AddPasswordHistory :exec
+INSERT INTO password_history (user_id, password_hash, created_at)
+VALUES ($1, $2, $3);
+
+-- name: ListPasswordHistory :many
+SELECT password_hash FROM password_history
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: PrunePasswordHistory :exec
+DELETE FROM password_history
+WHERE password_history.user_id = $1 AND password_history.created_at NOT IN (
+   SELECT ph.created_at FROM password_history ph
+   WHERE ph.user_id = $1
+   ORDER BY ph.created_at DESC
+   LIMIT $2
+);
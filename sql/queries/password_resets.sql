@@ -0,0 +1,29 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (id, user_id, code_hash, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = $2
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: GetLatestPasswordResetToken :one
+SELECT * FROM password_reset_tokens
+WHERE user_id = $1 AND used_at IS NULL
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetPasswordResetTokenByHash :one
+SELECT * FROM password_reset_tokens
+WHERE token_hash = $1 AND user_id = $2 AND used_at IS NULL;
+
+-- name: RecordPasswordResetAttempt :one
+UPDATE password_reset_tokens
+SET attempts = attempts + 1
+WHERE id = $1
+RETURNING attempts;
+
+-- name: UsePasswordResetToken :execrows
+UPDATE password_reset_tokens
+SET used_at = $2
+WHERE id = $1 AND used_at IS NULL;
@@ -0,0 +1,41 @@
+-- name: ListUsersToPurge :many
+SELECT id FROM users
+WHERE deleted_at < sqlc.arg(deleted_before)::timestamp
+  AND NOT EXISTS (
+     SELECT 1 FROM purge_failures
+     WHERE purge_failures.user_id = users.id AND purge_failures.retry_at > sqlc.arg(now)::timestamp
+  )
+ORDER BY deleted_at
+LIMIT sqlc.arg(batch_size);
+
+-- name: RecordPurgeFailure :exec
+-- Every failure doubles the wait before the next try, from an hour up to a day.
+INSERT INTO purge_failures (user_id, attempts, last_error, retry_at)
+VALUES (sqlc.arg(user_id), 1, sqlc.arg(last_error), sqlc.arg(failed_at)::timestamp + INTERVAL '1 hour')
+ON CONFLICT (user_id) DO UPDATE
+SET attempts = purge_failures.attempts + 1,
+    last_error = EXCLUDED.last_error,
+    retry_at = sqlc.arg(failed_at)::timestamp + LEAST(INTERVAL '1 hour' * POWER(2, LEAST(purge_failures.attempts, 5)), INTERVAL '1 day');
+
+-- name: LockUserToPurge :one
+SELECT id FROM users
+WHERE id = sqlc.arg(id) AND deleted_at < sqlc.arg(deleted_before)::timestamp
+FOR UPDATE;
+
+-- name: DeleteUserComments :exec
+DELETE FROM comments
+WHERE user_id = $1;
+
+-- name: DeleteUserMessages :exec
+DELETE FROM messages
+WHERE sender_id = sqlc.arg(user_id) OR receiver_id = sqlc.arg(user_id);
+
+-- name: AnonymizeUserReports :exec
+-- Reports outlive their author; the nil UUID stands in for the purged account.
+UPDATE reports
+SET reported_by = '00000000-0000-0000-0000-000000000000'
+WHERE reported_by = sqlc.arg(user_id);
+
+-- name: PurgeUser :exec
+DELETE FROM users
+WHERE id = $1;
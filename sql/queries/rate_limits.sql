@@ -0,0 +1,11 @@
+-- name: RecordRateLimitEvent :exec
+INSERT INTO rate_limit_events (subject, created_at)
+VALUES ($1, $2);
+
+-- name: CountRateLimitEvents :one
+SELECT COUNT(*) FROM rate_limit_events
+WHERE subject = $1 AND created_at > $2;
+
+-- name: DeleteRateLimitEventsBefore :exec
+DELETE FROM rate_limit_events
+WHERE created_at < $1;
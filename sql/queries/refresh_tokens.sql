@@ -0,0 +1,20 @@
+-- name: DeleteUserRefreshTokens :exec
+DELETE FROM refresh_tokens
+WHERE user_id = $1;
+
+-- name: ListSessions :many
+SELECT * FROM refresh_tokens
+WHERE user_id = sqlc.arg(user_id) AND expiry_time > sqlc.arg(now)::timestamp
+ORDER BY created_at DESC, id;
+
+-- name: GetSessionByTokenHash :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1 AND user_id = $2;
+
+-- name: RevokeSession :execrows
+DELETE FROM refresh_tokens
+WHERE id = $1 AND user_id = $2;
+
+-- name: RevokeOtherSessions :execrows
+DELETE FROM refresh_tokens
+WHERE user_id = sqlc.arg(user_id) AND id <> sqlc.arg(keep_id);
@@ -0,0 +1,40 @@
+-- name: UpsertTOTPSecret :execrows
+-- Only a secret that wasn't confirmed yet can be replaced.
+INSERT INTO totp_secrets (user_id, secret, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    created_at = EXCLUDED.created_at,
+    last_used_step = 0
+WHERE totp_secrets.confirmed_at IS NULL;
+
+-- name: GetTOTPSecret :one
+SELECT * FROM totp_secrets
+WHERE user_id = $1;
+
+-- name: ConfirmTOTPSecret :execrows
+UPDATE totp_secrets
+SET confirmed_at = $2, last_used_step = $3
+WHERE user_id = $1 AND confirmed_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE totp_secrets
+SET last_used_step = sqlc.arg(step)
+WHERE user_id = sqlc.arg(user_id) AND last_used_step < sqlc.arg(step);
+
+-- name: DeleteTOTPSecret :exec
+DELETE FROM totp_secrets
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (id, user_id, code_hash, created_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = $3
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
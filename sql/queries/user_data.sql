@@ -0,0 +1,55 @@
+-- name: ExportUserFollowing :many
+SELECT * FROM follows
+WHERE follower_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserFollowers :many
+SELECT * FROM follows
+WHERE followee_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserFollowRequests :many
+SELECT * FROM follow_requests
+WHERE requester_id = sqlc.arg(user_id) OR target_id = sqlc.arg(user_id)
+ORDER BY created_at;
+
+-- name: ExportUserBlocks :many
+SELECT * FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserUsernameHistory :many
+SELECT * FROM username_history
+WHERE user_id = $1
+ORDER BY released_at;
+
+-- name: ExportUserDeviceTokens :many
+SELECT * FROM device_tokens
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserRefreshTokens :many
+-- The tokens themselves are left out; they are credentials, not personal data.
+SELECT id, user_agent, ip, created_at, last_used_at, expiry_time FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserPosts :many
+SELECT * FROM posts
+WHERE posted_by = $1
+ORDER BY created_at;
+
+-- name: ExportUserComments :many
+SELECT * FROM comments
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserMessages :many
+SELECT * FROM messages
+WHERE sender_id = sqlc.arg(user_id) OR receiver_id = sqlc.arg(user_id)
+ORDER BY sent_at;
+
+-- name: ExportUserReports :many
+SELECT * FROM reports
+WHERE reported_by = $1
+ORDER BY reported_at;
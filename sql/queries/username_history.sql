@@ -0,0 +1,16 @@
+-- name: ReleaseUsername :exec
+INSERT INTO username_history (username, user_id, released_at, redirect_until)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (LOWER(username)) DO UPDATE
+SET username = EXCLUDED.username,
+    user_id = EXCLUDED.user_id,
+    released_at = EXCLUDED.released_at,
+    redirect_until = EXCLUDED.redirect_until;
+
+-- name: ReclaimUsername :exec
+DELETE FROM username_history
+WHERE LOWER(username) = LOWER(sqlc.arg(username));
+
+-- name: GetUsernameRedirect :one
+SELECT user_id FROM username_history
+WHERE LOWER(username) = LOWER(sqlc.arg(username)) AND redirect_until > sqlc.arg(now);
@@ -1,17 +1,47 @@
 -- name: GetUserByEmailOrUsername :one
 SELECT * FROM users
-WHERE email = $1 OR username = $2;
+WHERE (email = sqlc.arg(email) OR LOWER(username) = LOWER(sqlc.arg(username)))
+  AND deleted_at IS NULL;
 
 -- name: GetUserById :one
 SELECT * FROM users
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ListUsersNewestFirst :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg(is_premium)::boolean IS NULL OR is_premium = sqlc.narg(is_premium))
+  AND (sqlc.narg(is_verified)::boolean IS NULL OR is_verified = sqlc.narg(is_verified))
+  AND (sqlc.narg(created_after)::timestamp IS NULL OR created_at > sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamp IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+       OR (created_at, id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListUsersOldestFirst :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg(is_premium)::boolean IS NULL OR is_premium = sqlc.narg(is_premium))
+  AND (sqlc.narg(is_verified)::boolean IS NULL OR is_verified = sqlc.narg(is_verified))
+  AND (sqlc.narg(created_after)::timestamp IS NULL OR created_at > sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamp IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+       OR (created_at, id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
 
--- name: GetAllUsers :many
-SELECT * FROM users;
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg(is_premium)::boolean IS NULL OR is_premium = sqlc.narg(is_premium))
+  AND (sqlc.narg(is_verified)::boolean IS NULL OR is_verified = sqlc.narg(is_verified))
+  AND (sqlc.narg(created_after)::timestamp IS NULL OR created_at > sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamp IS NULL OR created_at < sqlc.narg(created_before));
 
 -- name: ChangeUsername :one
 UPDATE users
-SET username = $2, updated_at = NOW()
+SET username = $2, username_changed_at = $3, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
@@ -20,44 +50,117 @@ UPDATE users
 SET password = $2, updated_at = NOW()
 WHERE id = $1;
 
--- name: SubscribeUser :exec
-WITH subscribed_update AS (
-    UPDATE users
-    SET subscribed_to = array_append(subscribed_to, $1)
-    WHERE id = $2
-)
+-- name: SoftDeleteUser :exec
 UPDATE users
-SET subscribers = array_append(subscribers, $2)
-WHERE users.id = $1;
+SET deleted_at = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
 
--- name: UnsubscribeUser :exec
-WITH unsubscribed_update AS (
-    UPDATE users
-    SET subscribed_to = array_remove(subscribed_to, $1)
-    WHERE id = $2
-)
-UPDATE users
-SET subscribers = array_remove(subscribers, $2)
-WHERE users.id = $1;
+-- name: GetDeletedUserByEmailOrUsername :one
+SELECT * FROM users
+WHERE (email = sqlc.arg(email) OR LOWER(username) = LOWER(sqlc.arg(username)))
+  AND deleted_at IS NOT NULL;
 
--- name: DeleteUser :exec
-DELETE FROM users
-WHERE id = $1;
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = sqlc.arg(id) AND deleted_at > sqlc.arg(deleted_after)::timestamp
+RETURNING *;
 
 -- name: DeleteAllUsers :exec
 DELETE FROM users;
 
--- name: ResetPassword :exec
+-- name: StoreVerificationCode :execrows
 UPDATE users
-SET password = $2, updated_at = NOW()
+SET verification_code = 0,
+    verification_code_hash = sqlc.arg(code_hash),
+    verification_expire_time = sqlc.arg(expires_at),
+    verification_attempts = 0,
+    verification_sent_at = sqlc.arg(sent_at)
+WHERE id = sqlc.arg(id)
+  AND (verification_sent_at IS NULL OR verification_sent_at <= sqlc.arg(sent_before));
+
+-- name: RecordVerificationAttempt :one
+UPDATE users
+SET verification_attempts = verification_attempts + 1
+WHERE id = $1
+RETURNING verification_attempts;
+
+-- name: ClearVerificationCode :exec
+UPDATE users
+SET verification_code = 0,
+    verification_code_hash = '',
+    verification_attempts = 0
 WHERE id = $1;
 
--- name: SendResetVerificationCode :exec
+-- name: MarkEmailVerified :one
 UPDATE users
-SET verification_code = $2
+SET is_verified = TRUE, verified_at = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: IsUserVerified :one
+SELECT is_verified FROM users
 WHERE id = $1;
 
--- name: VerifyVerificationCode :exec
-UPDATE users 
-SET verification_code = 0
-WHERE id = $1;
\ No newline at end of file
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (username ILIKE sqlc.arg(prefix)::text
+       OR username % sqlc.arg(query)::text
+       OR (sqlc.arg(match_email)::boolean AND email = sqlc.arg(query)::text))
+ORDER BY
+   (sqlc.arg(match_email)::boolean AND email = sqlc.arg(query)::text) DESC,
+   (username ILIKE sqlc.arg(prefix)::text) DESC,
+   similarity(username, sqlc.arg(query)::text) DESC,
+   username
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: ExportUsersBatch :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg(is_premium)::boolean IS NULL OR is_premium = sqlc.narg(is_premium))
+  AND (sqlc.narg(is_verified)::boolean IS NULL OR is_verified = sqlc.narg(is_verified))
+  AND (sqlc.narg(created_after)::timestamp IS NULL OR created_at > sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamp IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(after_id)::uuid IS NULL OR id > sqlc.narg(after_id))
+ORDER BY id
+LIMIT sqlc.arg(batch_size);
+
+-- name: SetAccountPrivacy :one
+UPDATE users
+SET is_private = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateProfile :one
+UPDATE users
+SET display_name = CASE WHEN sqlc.arg(set_display_name)::boolean THEN sqlc.arg(display_name) ELSE display_name END,
+    bio = CASE WHEN sqlc.arg(set_bio)::boolean THEN sqlc.arg(bio) ELSE bio END,
+    avatar_url = CASE WHEN sqlc.arg(set_avatar_url)::boolean THEN sqlc.arg(avatar_url) ELSE avatar_url END,
+    links = CASE WHEN sqlc.arg(set_links)::boolean THEN sqlc.arg(links)::text[] ELSE links END,
+    location = CASE WHEN sqlc.arg(set_location)::boolean THEN sqlc.arg(location) ELSE location END,
+    birthday = CASE WHEN sqlc.arg(set_birthday)::boolean THEN sqlc.narg(birthday)::date ELSE birthday END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetUserRole :one
+SELECT role FROM users
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = sqlc.arg(role), updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GrantAdminRole :execrows
+UPDATE users
+SET role = 'admin', updated_at = NOW()
+WHERE id = $1 AND role <> 'admin' AND deleted_at IS NULL;
+
+-- name: RevokeUserRole :one
+UPDATE users
+SET role = 'user', updated_at = NOW()
+WHERE id = sqlc.arg(id) AND role = sqlc.arg(role)
+RETURNING *;
@@ -0,0 +1,44 @@
+-- name: CreateWebAuthnChallenge :exec
+INSERT INTO webauthn_challenges (id, user_id, ceremony, challenge, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: TakeWebAuthnChallenge :one
+-- A challenge is deleted as it is read, so it answers one response at most.
+DELETE FROM webauthn_challenges
+WHERE id = $1 AND ceremony = $2
+RETURNING *;
+
+-- name: DeleteExpiredWebAuthnChallenges :exec
+DELETE FROM webauthn_challenges
+WHERE expires_at < $1;
+
+-- name: CreateWebAuthnCredential :one
+INSERT INTO webauthn_credentials (
+   id, user_id, credential_id, name, public_key, algorithm, sign_count, aaguid,
+   transports, backup_eligible, backed_up, created_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING *;
+
+-- name: ListWebAuthnCredentials :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: GetWebAuthnCredentialByCredentialID :one
+SELECT * FROM webauthn_credentials
+WHERE credential_id = $1;
+
+-- name: UseWebAuthnCredential :one
+-- Only succeeds if nobody used the credential since it was read, so the sign count
+-- check can't be raced.
+UPDATE webauthn_credentials
+SET sign_count = sqlc.arg(sign_count), backed_up = sqlc.arg(backed_up), last_used_at = sqlc.arg(last_used_at)
+WHERE id = sqlc.arg(id) AND sign_count = sqlc.arg(previous_sign_count)
+RETURNING *;
+
+-- name: RenameWebAuthnCredential :one
+UPDATE webauthn_credentials
+SET name = $3
+WHERE id = $1 AND user_id = $2
+RETURNING *;
@@ -0,0 +1,7 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_users_username_trgm ON users USING GIN (username gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX idx_users_username_trgm;
@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX idx_users_created_at_id ON users(created_at, id);
+
+-- +goose Down
+DROP INDEX idx_users_created_at_id;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE follows (
+   follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   PRIMARY KEY (follower_id, followee_id),
+   CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX idx_follows_followee_id ON follows(followee_id);
+
+-- subscribed_to holds the users someone follows, subscribers holds their followers.
+-- Both sides were written by the same query, but read both in case they drifted.
+INSERT INTO follows (follower_id, followee_id)
+SELECT pairs.follower_id, pairs.followee_id
+FROM (
+   SELECT id AS follower_id, unnest(subscribed_to) AS followee_id FROM users
+   UNION
+   SELECT unnest(subscribers) AS follower_id, id AS followee_id FROM users
+) AS pairs
+JOIN users follower ON follower.id = pairs.follower_id
+JOIN users followee ON followee.id = pairs.followee_id
+WHERE pairs.follower_id <> pairs.followee_id
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN subscribers, DROP COLUMN subscribed_to;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN subscribers UUID[], ADD COLUMN subscribed_to UUID[];
+
+UPDATE users SET
+   subscribers = ARRAY(SELECT follower_id FROM follows WHERE followee_id = users.id ORDER BY created_at),
+   subscribed_to = ARRAY(SELECT followee_id FROM follows WHERE follower_id = users.id ORDER BY created_at);
+
+DROP TABLE follows;
@@ -0,0 +1,11 @@
+-- +goose Up
+DROP INDEX idx_follows_followee_id;
+
+CREATE INDEX idx_follows_followee_created_at ON follows(followee_id, created_at, follower_id);
+CREATE INDEX idx_follows_follower_created_at ON follows(follower_id, created_at, followee_id);
+
+-- +goose Down
+DROP INDEX idx_follows_follower_created_at;
+DROP INDEX idx_follows_followee_created_at;
+
+CREATE INDEX idx_follows_followee_id ON follows(followee_id);
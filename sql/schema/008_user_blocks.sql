@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE user_blocks (
+   blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   kind TEXT NOT NULL CHECK (kind IN ('block', 'mute')),
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   PRIMARY KEY (blocker_id, blocked_id),
+   CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked_id ON user_blocks(blocked_id);
+CREATE INDEX idx_user_blocks_blocker_created_at ON user_blocks(blocker_id, created_at, blocked_id);
+
+-- +goose Down
+DROP TABLE user_blocks;
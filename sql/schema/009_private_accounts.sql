@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE follow_requests (
+   requester_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   target_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   PRIMARY KEY (requester_id, target_id),
+   CHECK (requester_id <> target_id)
+);
+
+CREATE INDEX idx_follow_requests_target_created_at ON follow_requests(target_id, created_at, requester_id);
+
+-- +goose Down
+DROP TABLE follow_requests;
+
+ALTER TABLE users DROP COLUMN is_private;
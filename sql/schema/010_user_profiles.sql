@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+   ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+   ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+   ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '',
+   ADD COLUMN links TEXT[] NOT NULL DEFAULT '{}',
+   ADD COLUMN location TEXT NOT NULL DEFAULT '',
+   ADD COLUMN birthday DATE;
+
+-- +goose Down
+ALTER TABLE users
+   DROP COLUMN birthday,
+   DROP COLUMN location,
+   DROP COLUMN links,
+   DROP COLUMN avatar_url,
+   DROP COLUMN bio,
+   DROP COLUMN display_name;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE users
+   ADD COLUMN verification_code_hash TEXT NOT NULL DEFAULT '',
+   ADD COLUMN verification_attempts INT NOT NULL DEFAULT 0,
+   ADD COLUMN verification_sent_at TIMESTAMP;
+
+-- Codes issued before this migration were stored in plain text and never expired.
+UPDATE users SET verification_code = 0;
+
+-- +goose Down
+ALTER TABLE users
+   DROP COLUMN verification_sent_at,
+   DROP COLUMN verification_attempts,
+   DROP COLUMN verification_code_hash;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE password_reset_tokens (
+   id UUID PRIMARY KEY,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   code_hash TEXT NOT NULL,
+   token_hash TEXT UNIQUE,
+   attempts INT NOT NULL DEFAULT 0,
+   expires_at TIMESTAMP NOT NULL,
+   used_at TIMESTAMP,
+   created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_password_reset_tokens_user_created_at ON password_reset_tokens(user_id, created_at);
+
+-- One row per reset request or confirmation, keyed by what is being limited, e.g.
+-- 'request:ip:203.0.113.7'. Rows older than the rate limit window are pruned.
+CREATE TABLE password_reset_requests (
+   subject TEXT NOT NULL,
+   created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_password_reset_requests_subject_created_at ON password_reset_requests(subject, created_at);
+CREATE INDEX idx_password_reset_requests_created_at ON password_reset_requests(created_at);
+
+-- +goose Down
+DROP TABLE password_reset_requests;
+DROP TABLE password_reset_tokens;
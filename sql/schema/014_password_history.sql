@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE password_history (
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   password_hash TEXT NOT NULL,
+   created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_password_history_user_created_at ON password_history(user_id, created_at);
+
+-- Password changes delete every refresh token of the user.
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+-- +goose Down
+DROP INDEX idx_refresh_tokens_user_id;
+DROP TABLE password_history;
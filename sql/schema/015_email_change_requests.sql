@@ -0,0 +1,13 @@
+-- +goose Up
+-- A user has at most one pending email change; a new request replaces the old one.
+CREATE TABLE email_change_requests (
+   user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+   new_email TEXT NOT NULL,
+   code_hash TEXT NOT NULL,
+   attempts INT NOT NULL DEFAULT 0,
+   expires_at TIMESTAMP NOT NULL,
+   created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE email_change_requests;
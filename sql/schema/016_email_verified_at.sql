@@ -0,0 +1,6 @@
+-- +goose Up
+-- Accounts verified before this migration keep is_verified without a timestamp.
+ALTER TABLE users ADD COLUMN verified_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN verified_at;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- Usernames were never unique. Older accounts keep their handle; newer duplicates get
+-- the start of their id appended so the unique index below can be built.
+UPDATE users
+SET username = users.username || '_' || SUBSTRING(users.id::text, 1, 8)
+FROM (
+   SELECT id, ROW_NUMBER() OVER (PARTITION BY LOWER(username) ORDER BY created_at, id) AS position
+   FROM users
+) ranked
+WHERE users.id = ranked.id AND ranked.position > 1;
+
+DROP INDEX idx_users_username;
+CREATE UNIQUE INDEX idx_users_username_lower ON users(LOWER(username));
+
+ALTER TABLE users ADD COLUMN username_changed_at TIMESTAMP;
+
+-- Handles given up by a username change. Until redirect_until, looking the old handle
+-- up finds its former owner, and only they can take it back.
+CREATE TABLE username_history (
+   username TEXT NOT NULL,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   released_at TIMESTAMP NOT NULL,
+   redirect_until TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_username_history_username_lower ON username_history(LOWER(username));
+CREATE INDEX idx_username_history_user_id ON username_history(user_id);
+
+-- +goose Down
+DROP TABLE username_history;
+ALTER TABLE users DROP COLUMN username_changed_at;
+DROP INDEX idx_users_username_lower;
+CREATE INDEX idx_users_username ON users(username);
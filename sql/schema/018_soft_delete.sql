@@ -0,0 +1,10 @@
+-- +goose Up
+-- Deleted accounts are kept for a grace period so they can be restored; the purge
+-- worker removes them for good once it has passed.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- Archives of everything the service holds about a user, built in the background.
+-- status moves from 'pending' to 'running' to 'ready' or 'failed'.
+CREATE TABLE data_exports (
+   id UUID PRIMARY KEY,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   status TEXT NOT NULL CHECK (status IN ('pending', 'running', 'ready', 'failed')),
+   error TEXT NOT NULL DEFAULT '',
+   size_bytes BIGINT NOT NULL DEFAULT 0,
+   created_at TIMESTAMP NOT NULL,
+   started_at TIMESTAMP,
+   completed_at TIMESTAMP,
+   expires_at TIMESTAMP
+);
+
+CREATE INDEX idx_data_exports_user_created_at ON data_exports(user_id, created_at);
+CREATE INDEX idx_data_exports_pending ON data_exports(created_at) WHERE status IN ('pending', 'running');
+-- A user has at most one export being built at a time.
+CREATE UNIQUE INDEX idx_data_exports_user_in_progress ON data_exports(user_id) WHERE status IN ('pending', 'running');
+
+-- Kept apart from data_exports so status checks don't read the archive.
+CREATE TABLE data_export_archives (
+   export_id UUID PRIMARY KEY REFERENCES data_exports(id) ON DELETE CASCADE,
+   archive BYTEA NOT NULL
+);
+
+-- +goose Down
+DROP TABLE data_export_archives;
+DROP TABLE data_exports;
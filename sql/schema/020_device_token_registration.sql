@@ -0,0 +1,25 @@
+-- +goose Up
+-- A device token identifies one app install, so it can only belong to one user. Keep
+-- the latest registration of every token before making tokens unique.
+DELETE FROM device_tokens
+WHERE id IN (
+   SELECT id FROM (
+      SELECT id, ROW_NUMBER() OVER (PARTITION BY device_token ORDER BY updated_at DESC, id) AS position
+      FROM device_tokens
+   ) ranked
+   WHERE ranked.position > 1
+);
+
+CREATE UNIQUE INDEX idx_device_tokens_device_token ON device_tokens(device_token);
+
+-- Apps register their token on every start; tokens not seen for a while belong to
+-- uninstalled apps and are pruned.
+ALTER TABLE device_tokens ADD COLUMN last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+UPDATE device_tokens SET last_seen_at = updated_at;
+
+CREATE INDEX idx_device_tokens_last_seen_at ON device_tokens(last_seen_at);
+
+-- +goose Down
+DROP INDEX idx_device_tokens_last_seen_at;
+ALTER TABLE device_tokens DROP COLUMN last_seen_at;
+DROP INDEX idx_device_tokens_device_token;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- Each refresh token is a session the user can see and revoke. Sessions get an id of
+-- their own and are found by the SHA-256 of their token. This is the first of two
+-- steps: the auth service still writes and looks up the raw token, so it stays, and a
+-- trigger fills in token_hash for every token it writes. Once the auth service uses
+-- token_hash, a later migration can drop the raw token.
+ALTER TABLE refresh_tokens ADD COLUMN id UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX idx_refresh_tokens_id ON refresh_tokens(id);
+
+ALTER TABLE refresh_tokens ADD COLUMN token_hash TEXT;
+UPDATE refresh_tokens SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash SET NOT NULL;
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
+
+-- +goose StatementBegin
+CREATE FUNCTION hash_refresh_token() RETURNS trigger AS $$
+BEGIN
+   NEW.token_hash := encode(sha256(convert_to(NEW.token, 'UTF8')), 'hex');
+   RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER refresh_tokens_hash_token
+BEFORE INSERT OR UPDATE OF token ON refresh_tokens
+FOR EACH ROW EXECUTE FUNCTION hash_refresh_token();
+
+-- +goose Down
+DROP TRIGGER refresh_tokens_hash_token ON refresh_tokens;
+DROP FUNCTION hash_refresh_token;
+
+DROP INDEX idx_refresh_tokens_token_hash;
+ALTER TABLE refresh_tokens DROP COLUMN token_hash;
+DROP INDEX idx_refresh_tokens_id;
+ALTER TABLE refresh_tokens DROP COLUMN id;
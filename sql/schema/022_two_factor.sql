@@ -0,0 +1,27 @@
+-- +goose Up
+-- The TOTP secret of a user, sealed with TOTP_ENCRYPTION_KEY. Two-factor
+-- authentication is on once confirmed_at is set; until then EnrollTOTP may replace
+-- the secret. last_used_step is the time step of the last code accepted, so a code
+-- can't be used twice.
+CREATE TABLE totp_secrets (
+   user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+   secret BYTEA NOT NULL,
+   confirmed_at TIMESTAMP,
+   last_used_step BIGINT NOT NULL DEFAULT 0,
+   created_at TIMESTAMP NOT NULL
+);
+
+-- Single-use codes standing in for a TOTP code, stored as keyed hashes.
+CREATE TABLE recovery_codes (
+   id UUID PRIMARY KEY,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   code_hash TEXT NOT NULL,
+   used_at TIMESTAMP,
+   created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_recovery_codes_user_code_hash ON recovery_codes(user_id, code_hash);
+
+-- +goose Down
+DROP TABLE recovery_codes;
+DROP TABLE totp_secrets;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- Passkeys of users. credential_id is chosen by the authenticator and public_key is
+-- a COSE_Key; sign_count only ever grows for authenticators that keep a counter.
+CREATE TABLE webauthn_credentials (
+   id UUID PRIMARY KEY,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   credential_id BYTEA NOT NULL UNIQUE,
+   name TEXT NOT NULL,
+   public_key BYTEA NOT NULL,
+   algorithm INT NOT NULL,
+   sign_count BIGINT NOT NULL DEFAULT 0,
+   aaguid UUID NOT NULL,
+   transports TEXT[] NOT NULL DEFAULT '{}',
+   backup_eligible BOOLEAN NOT NULL,
+   backed_up BOOLEAN NOT NULL,
+   created_at TIMESTAMP NOT NULL,
+   last_used_at TIMESTAMP
+);
+
+CREATE INDEX idx_webauthn_credentials_user_created_at ON webauthn_credentials(user_id, created_at);
+
+-- Challenges of ceremonies in progress, used once. user_id is NULL for assertions,
+-- since the user is only known from the credential that answers.
+CREATE TABLE webauthn_challenges (
+   id UUID PRIMARY KEY,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   ceremony TEXT NOT NULL CHECK (ceremony IN ('registration', 'assertion')),
+   challenge BYTEA NOT NULL,
+   expires_at TIMESTAMP NOT NULL,
+   created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_webauthn_challenges_expires_at ON webauthn_challenges(expires_at);
+
+-- +goose Down
+DROP TABLE webauthn_challenges;
+DROP TABLE webauthn_credentials;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- A user has at most one pending email verification. Its code is kept apart from
+-- users.verification_code_hash, which holds password reset codes, so neither code
+-- works for the other flow and requesting one doesn't cancel the other.
+CREATE TABLE email_verifications (
+   user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+   code_hash TEXT NOT NULL,
+   attempts INT NOT NULL DEFAULT 0,
+   expires_at TIMESTAMP NOT NULL,
+   sent_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE email_verifications;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Accounts the purge worker failed to delete. They are skipped until retry_at, so one
+-- broken account doesn't hold up the others or get retried on every run.
+CREATE TABLE purge_failures (
+   user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+   attempts INT NOT NULL,
+   last_error TEXT NOT NULL,
+   retry_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE purge_failures;
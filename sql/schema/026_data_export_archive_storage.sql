@@ -0,0 +1,8 @@
+-- +goose Up
+-- Archives are zips, which don't compress further, and are downloaded in chunks with
+-- substring(). Stored uncompressed out of line, each chunk reads only its own TOAST
+-- slices instead of decompressing the archive from the start.
+ALTER TABLE data_export_archives ALTER COLUMN archive SET STORAGE EXTERNAL;
+
+-- +goose Down
+ALTER TABLE data_export_archives ALTER COLUMN archive SET STORAGE EXTENDED;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- What a user needs to tell sessions apart, e.g. to find a lost phone. Refresh tokens
+-- are written by the auth service, so it fills these in: user_agent and ip when it
+-- issues a token, and last_used_at each time the token is used to refresh. Sessions
+-- written before it does stay empty.
+ALTER TABLE refresh_tokens
+   ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+   ADD COLUMN ip TEXT NOT NULL DEFAULT '',
+   ADD COLUMN last_used_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+   DROP COLUMN last_used_at,
+   DROP COLUMN ip,
+   DROP COLUMN user_agent;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Every rate-limited RPC records its attempts here, not only password resets.
+ALTER TABLE password_reset_requests RENAME TO rate_limit_events;
+ALTER INDEX idx_password_reset_requests_subject_created_at RENAME TO idx_rate_limit_events_subject_created_at;
+ALTER INDEX idx_password_reset_requests_created_at RENAME TO idx_rate_limit_events_created_at;
+
+-- +goose Down
+ALTER INDEX idx_rate_limit_events_created_at RENAME TO idx_password_reset_requests_created_at;
+ALTER INDEX idx_rate_limit_events_subject_created_at RENAME TO idx_password_reset_requests_subject_created_at;
+ALTER TABLE rate_limit_events RENAME TO password_reset_requests;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Emails are compared case-insensitively when checking whether one is taken.
+CREATE INDEX idx_users_email_lower ON users(lower(email));
+
+-- +goose Down
+DROP INDEX idx_users_email_lower;